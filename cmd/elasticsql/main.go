@@ -0,0 +1,167 @@
+// Command elasticsql converts sql to elasticsearch dsl
+//
+//	elasticsql [flags] [sql ...]
+//
+// the sql is read from the args, the file of -f, or stdin if there are neither,
+// the statements are separated by ;, each one is printed as the request of the kibana console:
+//
+//	POST /index/_search
+//	{ ... dsl ... }
+//
+// with -url the statements are executed, the rows of select are printed as a table,
+// -i starts an interactive shell, see the help command of the shell
+//
+// the exit code is 2 for syntax errors, 3 for unsupported sql, 4 for invalid values and 1 for the others
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cch123/elasticsql"
+	"github.com/cch123/elasticsql/esdriver"
+)
+
+// the exit codes of the errors
+const (
+	exitOK = iota
+	exitError
+	exitSyntax
+	exitUnsupported
+	exitInvalidValue
+)
+
+var exitCodes = map[elasticsql.ErrorCode]int{
+	elasticsql.ErrCodeSyntax:       exitSyntax,
+	elasticsql.ErrCodeUnsupported:  exitUnsupported,
+	elasticsql.ErrCodeInvalidValue: exitInvalidValue,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("elasticsql", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		file    = flags.String("f", "", "read the sql from the file, - for stdin")
+		compact = flags.Bool("compact", false, "print the dsl in one line")
+		target  = flags.String("target", "default", "target version of the dsl: default, es2, es5, es6, es7, es8, opensearch")
+		docType = flags.String("type", "", "the _type of the inserted documents before es7, _doc for es6 if not set")
+		term    = flags.Bool("term", false, "translate = and != to term instead of match_phrase")
+		url     = flags.String("url", "", "execute the statements on the elasticsearch of the url, eg. http://localhost:9200")
+		shell   = flags.Bool("i", false, "start the interactive shell")
+		history = flags.String("history", "", "the file the history of the interactive shell is kept in")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: elasticsql [flags] [sql ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	var opts elasticsql.Options
+	var err error
+	opts.TargetVersion, err = elasticsql.ParseVersion(*target)
+	if err != nil {
+		return printError(stderr, err)
+	}
+	opts.DocType = *docType
+	if *term {
+		opts.Equality = elasticsql.TermEquality
+	}
+
+	var db *sql.DB
+	if *url != "" {
+		db = sql.OpenDB(esdriver.NewConnector(*url, opts, nil))
+		defer db.Close()
+	}
+
+	if *shell {
+		r := newREPL(stdin, stdout, opts, db)
+		r.compact = *compact
+		if err = r.loadHistory(*history); err != nil {
+			return printError(stderr, err)
+		}
+		return r.run()
+	}
+
+	input, err := readSQL(flags.Args(), *file, stdin)
+	if err != nil {
+		return printError(stderr, err)
+	}
+	statements, err := elasticsql.SplitStatements(input)
+	if err != nil {
+		return printError(stderr, err)
+	}
+	if len(statements) == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	for i, statement := range statements {
+		stmt, err := elasticsql.ConvertStatement(statement, opts)
+		if err != nil {
+			return printError(stderr, err)
+		}
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		if err = printStatement(stdout, stmt, *compact); err != nil {
+			return printError(stderr, err)
+		}
+		if db == nil {
+			continue
+		}
+		if err = execute(db, stmt, statement, stdout); err != nil {
+			return printError(stderr, err)
+		}
+	}
+	return exitOK
+}
+
+// printStatement prints the statement as the request of the kibana console
+func printStatement(out io.Writer, stmt *elasticsql.Statement, compact bool) error {
+	// the ndjson of bulk is always one json in one line
+	var dsl bytes.Buffer
+	if compact || stmt.Endpoint == elasticsql.BulkEndpoint {
+		dsl.WriteString(strings.TrimSuffix(stmt.DSL, "\n"))
+	} else if err := json.Indent(&dsl, []byte(stmt.DSL), "", "  "); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "POST /%s/%s\n%s\n", stmt.Table, stmt.Endpoint, dsl.String())
+	return err
+}
+
+// readSQL joins the args as the sql, or reads the file, or stdin
+func readSQL(args []string, file string, stdin io.Reader) (string, error) {
+	switch {
+	case len(args) > 0 && file != "":
+		return "", errors.New("elasticsql: either the args or -f can be used, not both")
+	case len(args) > 0:
+		return strings.Join(args, " "), nil
+	case file != "" && file != "-":
+		b, err := ioutil.ReadFile(file)
+		return string(b), err
+	}
+	b, err := ioutil.ReadAll(stdin)
+	return string(b), err
+}
+
+func printError(stderr io.Writer, err error) int {
+	fmt.Fprintln(stderr, err)
+	if code, ok := exitCodes[elasticsql.ErrorCodeOf(err)]; ok {
+		return code
+	}
+	return exitError
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var runCaseList = []struct {
+	args   []string
+	stdin  string
+	code   int
+	stdout string
+}{
+	{[]string{"-compact", "select * from a where id = 1"}, "", exitOK, "POST /a/_search\n" + `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"from":0,"size":1}` + "\n"},
+	{[]string{"-compact", "-term"}, "select * from a where id = 1;\ndelete from b where id = 2;", exitOK, "POST /a/_search\n" + `{"query":{"bool":{"must":[{"term":{"id":1}}]}},"from":0,"size":1}` + "\n\nPOST /b/_delete_by_query\n" + `{"query":{"bool":{"must":[{"term":{"id":2}}]}}}` + "\n"},
+	{[]string{"-target", "es8", "select count(*) from a group by date_histogram(field='t', value='1d')"}, "", exitOK, "POST /a/_search\n{\n  \"query\": {\n    \"bool\": {\n      \"must\": [\n        {\n          \"match_all\": {}\n        }\n      ]\n    }\n  },\n  \"from\": 0,\n  \"size\": 0,\n  \"aggregations\": {\n    \"date_histogram(field=t,value=1d)\": {\n      \"aggregations\": {\n        \"COUNT(*)\": {\n          \"value_count\": {\n            \"field\": \"_index\"\n          }\n        }\n      },\n      \"date_histogram\": {\n        \"calendar_interval\": \"1d\",\n        \"field\": \"t\",\n        \"format\": \"yyyy-MM-dd HH:mm:ss\"\n      }\n    }\n  }\n}\n"},
+	{[]string{"insert into a (_id, x) values (1, 2)"}, "", exitOK, "POST /a/_bulk\n" + `{"create":{"_id":"1","_index":"a"}}` + "\n" + `{"x":2}` + "\n"},
+	{[]string{"-target", "es6", "-type", "doc", "insert into a (_id, x) values (1, 2)"}, "", exitOK, "POST /a/_bulk\n" + `{"create":{"_id":"1","_index":"a","_type":"doc"}}` + "\n" + `{"x":2}` + "\n"},
+	{[]string{"select * frm a"}, "", exitSyntax, ""},
+	{[]string{"select * from a; select * from a where a <=> 1"}, "", exitUnsupported, "POST /a/_search\n{\n  \"query\": {\n    \"bool\": {\n      \"must\": [\n        {\n          \"match_all\": {}\n        }\n      ]\n    }\n  },\n  \"from\": 0,\n  \"size\": 1\n}\n"},
+	{[]string{"select * from a limit 'x'"}, "", exitInvalidValue, ""},
+	{[]string{"-target", "es9", "select * from a"}, "", exitInvalidValue, ""},
+	{[]string{}, " ; ", exitError, ""},
+	{[]string{"-x"}, "", exitError, ""},
+}
+
+func TestRun(t *testing.T) {
+	for _, c := range runCaseList {
+		var stdout, stderr bytes.Buffer
+		code := run(c.args, strings.NewReader(c.stdin), &stdout, &stderr)
+		if code != c.code {
+			t.Error("the exit code is not equal to expected", c.args, code, stderr.String())
+		}
+		if stdout.String() != c.stdout {
+			t.Error("the output is not equal to expected", c.args, stdout.String())
+		}
+		if code != exitOK && stderr.Len() == 0 {
+			t.Error("can not be true, the error must be printed", c.args)
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elasticsql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a.sql")
+	ioutil.WriteFile(file, []byte("update a set x = 1 where id = 2"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-compact", "-f", file}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Error("the exit code is not equal to expected", code, stderr.String())
+	}
+	expected := "POST /a/_update_by_query\n" + `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":2}}}]}},"script":{"lang":"painless","params":{"p0":1},"source":"ctx._source['x'] = params.p0"}}` + "\n"
+	if stdout.String() != expected {
+		t.Error("the output is not equal to expected", stdout.String())
+	}
+
+	if code := run([]string{"-f", file, "select * from a"}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Error("can not be true, the args and -f can not be used together", code)
+	}
+	if code := run([]string{"-f", filepath.Join(dir, "missing.sql")}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Error("can not be true, the file does not exist", code)
+	}
+}
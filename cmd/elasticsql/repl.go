@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cch123/elasticsql"
+	"github.com/xwb1989/sqlparser"
+)
+
+const (
+	prompt             = "elasticsql> "
+	continuationPrompt = "         -> "
+)
+
+const replHelp = `the statements end with ;, and may span multiple lines
+  \h, help     show this help
+  \q, quit     exit the shell
+  \c           clear the statement being typed
+  \x           turn the execution on elasticsearch on or off, it is on when -url is set
+  \history     list the history
+  !N           run the statement N of the history again, !! runs the last one
+`
+
+// repl is the interactive shell, the statements are converted and printed,
+// and executed when there is a db
+type repl struct {
+	in      *bufio.Scanner
+	out     io.Writer
+	opts    elasticsql.Options
+	db      *sql.DB
+	execute bool
+	compact bool
+
+	history []string
+	// historyFile keeps the history between the sessions, one statement in one line
+	historyFile string
+}
+
+func newREPL(in io.Reader, out io.Writer, opts elasticsql.Options, db *sql.DB) *repl {
+	return &repl{
+		in:      bufio.NewScanner(in),
+		out:     out,
+		opts:    opts,
+		db:      db,
+		execute: db != nil,
+	}
+}
+
+// loadHistory reads the history of the previous sessions, the file is created when the first statement is run
+func (r *repl) loadHistory(file string) error {
+	r.historyFile = file
+	if file == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) != "" {
+			r.history = append(r.history, historyUnescaper.Replace(line))
+		}
+	}
+	return nil
+}
+
+// the statements are kept as they are typed, the new lines in them are escaped in the history file
+var (
+	historyEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	historyUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func (r *repl) addHistory(statement string) {
+	r.history = append(r.history, statement)
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, historyEscaper.Replace(statement))
+}
+
+// run reads the statements until quit or the end of the input
+func (r *repl) run() int {
+	var buf strings.Builder
+	for {
+		if buf.Len() == 0 {
+			io.WriteString(r.out, prompt)
+		} else {
+			io.WriteString(r.out, continuationPrompt)
+		}
+		if !r.in.Scan() {
+			break
+		}
+		line := r.in.Text()
+
+		if strings.TrimSpace(line) == `\c` {
+			buf.Reset()
+			continue
+		}
+		if buf.Len() == 0 {
+			command := strings.TrimSpace(line)
+			if command == "" {
+				continue
+			}
+			if quit, ok := r.runCommand(command); ok {
+				if quit {
+					return exitOK
+				}
+				continue
+			}
+		}
+
+		buf.WriteString(line + "\n")
+		if isComplete(buf.String()) {
+			r.runStatements(buf.String())
+			buf.Reset()
+		}
+	}
+
+	// the last statement may have no ;
+	if strings.TrimSpace(buf.String()) != "" {
+		io.WriteString(r.out, "\n")
+		r.runStatements(buf.String())
+	}
+	io.WriteString(r.out, "\n")
+	if err := r.in.Err(); err != nil {
+		fmt.Fprintln(r.out, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// runCommand runs the command of the shell, ok is false if the line is not a command
+func (r *repl) runCommand(command string) (quit bool, ok bool) {
+	switch strings.ToLower(strings.TrimSuffix(command, ";")) {
+	case `\q`, "quit", "exit":
+		return true, true
+	case `\h`, "help":
+		io.WriteString(r.out, replHelp)
+	case `\x`:
+		if r.db == nil {
+			io.WriteString(r.out, "no elasticsearch to execute on, start the shell with -url\n")
+			break
+		}
+		r.execute = !r.execute
+		if r.execute {
+			io.WriteString(r.out, "execution is on\n")
+		} else {
+			io.WriteString(r.out, "execution is off\n")
+		}
+	case `\history`:
+		for i, statement := range r.history {
+			// the lines after the first one are aligned with it
+			fmt.Fprintf(r.out, "%5d  %s\n", i+1, strings.Replace(statement, "\n", "\n       ", -1))
+		}
+	default:
+		if !strings.HasPrefix(command, "!") {
+			return false, false
+		}
+		index := len(r.history)
+		if command != "!!" {
+			var err error
+			index, err = strconv.Atoi(command[1:])
+			if err != nil {
+				return false, false
+			}
+		}
+		if index < 1 || index > len(r.history) {
+			fmt.Fprintf(r.out, "%s: event not found\n", command)
+			break
+		}
+		fmt.Fprintln(r.out, r.history[index-1])
+		r.runStatements(r.history[index-1])
+	}
+	return false, true
+}
+
+// runStatements converts, prints and executes the statements, the errors are printed
+func (r *repl) runStatements(input string) {
+	statements, err := elasticsql.SplitStatements(input)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	for _, statement := range statements {
+		r.addHistory(statement)
+		stmt, err := elasticsql.ConvertStatement(statement, r.opts)
+		if err == nil {
+			err = printStatement(r.out, stmt, r.compact)
+		}
+		if err == nil && r.execute {
+			err = execute(r.db, stmt, statement, r.out)
+		}
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+		}
+	}
+}
+
+// isComplete checks whether the input ends with ;, the ; in quotes and comments are not counted
+func isComplete(input string) bool {
+	tokenizer := sqlparser.NewStringTokenizer(input)
+	var last int
+	for {
+		tkn, _ := tokenizer.Scan()
+		switch tkn {
+		case 0:
+			return last == ';'
+		case sqlparser.LEX_ERROR:
+			return false
+		case sqlparser.COMMENT:
+			continue
+		}
+		last = tkn
+	}
+}
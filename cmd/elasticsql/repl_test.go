@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cch123/elasticsql"
+	"github.com/cch123/elasticsql/esdriver"
+	"github.com/cch123/elasticsql/internal/estest"
+)
+
+// the canned responses of the paths
+var responseMap = map[string]string{
+	"/orders/_search":          `{"hits":{"hits":[]},"aggregations":{"region":{"buckets":[{"key":"bj","doc_count":12,"total":{"value":12}},{"key":"shanghai","doc_count":3,"total":{"value":3}}]}}}`,
+	"/users/_search":           `{"hits":{"hits":[{"_id":"1","_source":{"id":1,"name":"x","score":1.5}},{"_id":"2","_source":{"id":2,"tags":["a"]}}]}}`,
+	"/empty/_search":           `{"hits":{"hits":[]}}`,
+	"/orders/_delete_by_query": `{"took":1,"deleted":3}`,
+}
+
+func TestREPL(t *testing.T) {
+	server := estest.NewServer(responseMap, nil)
+	defer server.Close()
+	db := sql.OpenDB(esdriver.NewConnector(server.URL, elasticsql.Options{}, server.Client()))
+	defer db.Close()
+
+	input := strings.Join([]string{
+		"select region, count(*) as total",
+		"from orders group by region;",
+		"select id, name, score, tags from users; select * from empty;",
+		"delete from orders where id = 1;",
+		"select * from missing;",
+		"select * from a where a <=> 1;",
+		`\x`,
+		"select * from users where name = ';'",
+		"  and id = 1;",
+		`\history`,
+	}, "\n")
+	var out bytes.Buffer
+	r := newREPL(strings.NewReader(input), &out, elasticsql.Options{}, db)
+	r.compact = true
+	if code := r.run(); code != exitOK {
+		t.Error("the exit code is not equal to expected", code)
+	}
+
+	for _, expected := range []string{
+		"elasticsql>          -> POST /orders/_search\n",
+		"+----------+-------+\n| region   | total |\n+----------+-------+\n| bj       |    12 |\n| shanghai |     3 |\n+----------+-------+\n2 rows in set\n",
+		"+----+------+-------+-------+\n| id | name | score | tags  |\n+----+------+-------+-------+\n|  1 | x    |   1.5 | NULL  |\n|  2 | NULL | NULL  | [\"a\"] |\n+----+------+-------+-------+\n2 rows in set\n",
+		"POST /empty/_search\n{\"query\":{\"bool\":{\"must\":[{\"match_all\":{}}]}},\"from\":0,\"size\":1}\nEmpty set\n",
+		"POST /orders/_delete_by_query\n",
+		"3 rows affected\n",
+		"elasticsql: index_not_found_exception: no such index\n",
+		"elasticsql: unsupported comparison operator, got <=>\n",
+		"execution is off\n",
+		"elasticsql>          -> POST /users/_search\n" + `{"query":{"bool":{"must":[{"match_phrase":{"name":{"query":";"}}},{"match_phrase":{"id":{"query":1}}}]}},"from":0,"size":1}`,
+		"    7  select * from users where name = ';'\n         and id = 1\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Error("the output does not contain the expected", expected, out.String())
+		}
+	}
+}
+
+func TestREPLHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elasticsql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history")
+	ioutil.WriteFile(file, []byte("select * from a where id = 1\n"), 0600)
+
+	var out bytes.Buffer
+	r := newREPL(strings.NewReader("!1\nselect * from b;\n!!\nupdate b set x = 'a  b' -- note\nwhere id = 1;\n!!\n!9\n\\x\nselect * from c\n\\c\n\\h\nquit\nselect * from d;\n"), &out, elasticsql.Options{}, nil)
+	r.compact = true
+	if err = r.loadHistory(file); err != nil {
+		t.Fatal(err)
+	}
+	r.run()
+
+	for _, expected := range []string{
+		"elasticsql> select * from a where id = 1\nPOST /a/_search\n",
+		"elasticsql> select * from b\nPOST /b/_search\n",
+		"elasticsql> update b set x = 'a  b' -- note\nwhere id = 1\nPOST /b/_update_by_query\n" + `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"script":{"lang":"painless","params":{"p0":"a  b"},"source":"ctx._source['x'] = params.p0"}}`,
+		"!9: event not found\n",
+		"no elasticsearch to execute on",
+		`\history     list the history`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Error("the output does not contain the expected", expected, out.String())
+		}
+	}
+	// the statement cleared by \c and the ones after quit are not run
+	for _, unexpected := range []string{"POST /c/", "POST /d/"} {
+		if strings.Contains(out.String(), unexpected) {
+			t.Error("can not be true, the statement is not run", unexpected)
+		}
+	}
+
+	// the statements are run again as they are typed, the comment does not swallow the where
+	b, _ := ioutil.ReadFile(file)
+	if string(b) != "select * from a where id = 1\nselect * from a where id = 1\nselect * from b\nselect * from b\nupdate b set x = 'a  b' -- note\\nwhere id = 1\nupdate b set x = 'a  b' -- note\\nwhere id = 1\n" {
+		t.Error("the history file is not equal to expected", string(b))
+	}
+
+	r = newREPL(strings.NewReader(""), &out, elasticsql.Options{}, nil)
+	if err = r.loadHistory(file); err != nil || r.history[len(r.history)-1] != "update b set x = 'a  b' -- note\nwhere id = 1" {
+		t.Error("the history loaded is not equal to expected", r.history, err)
+	}
+}
+
+func TestRunExecute(t *testing.T) {
+	server := estest.NewServer(responseMap, nil)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-compact", "-url", server.URL, "delete from orders where id = 1"}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitOK || !strings.HasSuffix(stdout.String(), "3 rows affected\n") {
+		t.Error("the output is not equal to expected", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	code = run([]string{"-i", "-url", server.URL}, strings.NewReader("select * from empty;\n"), &stdout, &stderr)
+	if code != exitOK || !strings.Contains(stdout.String(), "Empty set\n") {
+		t.Error("the output is not equal to expected", code, stdout.String(), stderr.String())
+	}
+
+	code = run([]string{"-url", server.URL, "select * from missing"}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitError {
+		t.Error("the exit code is not equal to expected", code)
+	}
+}
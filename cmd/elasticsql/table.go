@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cch123/elasticsql"
+)
+
+// execute runs the statement on elasticsearch, the rows of select are printed as a table,
+// and the number of the changed documents for the others
+func execute(db *sql.DB, stmt *elasticsql.Statement, statement string, out io.Writer) error {
+	if stmt.Endpoint != elasticsql.SearchEndpoint {
+		result, err := db.Exec(statement)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%d rows affected\n", affected)
+		return err
+	}
+
+	rows, err := db.Query(statement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var cells [][]cell
+	for rows.Next() {
+		var values = make([]interface{}, len(columns))
+		var dest = make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		var row = make([]cell, len(columns))
+		for i, val := range values {
+			row[i] = newCell(val)
+		}
+		cells = append(cells, row)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	writeTable(out, columns, cells)
+	return nil
+}
+
+// cell is a value in the table, the numbers are aligned to the right
+type cell struct {
+	text    string
+	numeric bool
+}
+
+func newCell(val interface{}) cell {
+	switch v := val.(type) {
+	case nil:
+		return cell{text: "NULL"}
+	case int64:
+		return cell{text: strconv.FormatInt(v, 10), numeric: true}
+	case float64:
+		return cell{text: strconv.FormatFloat(v, 'f', -1, 64), numeric: true}
+	case []byte:
+		return cell{text: string(v)}
+	}
+	return cell{text: fmt.Sprint(val)}
+}
+
+// writeTable prints the rows in the format of the mysql client
+//
+//	+--------+-------+
+//	| region | total |
+//	+--------+-------+
+//	| bj     |     2 |
+//	+--------+-------+
+//	1 row in set
+func writeTable(out io.Writer, columns []string, rows [][]cell) {
+	// * has no columns when there are no hits
+	if len(columns) == 0 {
+		io.WriteString(out, "Empty set\n")
+		return
+	}
+
+	var widths = make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	for _, row := range rows {
+		for i, c := range row {
+			if width := utf8.RuneCountInString(c.text); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	var border strings.Builder
+	border.WriteString("+")
+	for _, width := range widths {
+		border.WriteString(strings.Repeat("-", width+2) + "+")
+	}
+	border.WriteString("\n")
+
+	writeRow := func(row []cell) {
+		var line strings.Builder
+		line.WriteString("|")
+		for i, c := range row {
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c.text))
+			if c.numeric {
+				line.WriteString(" " + padding + c.text + " |")
+			} else {
+				line.WriteString(" " + c.text + padding + " |")
+			}
+		}
+		line.WriteString("\n")
+		io.WriteString(out, line.String())
+	}
+
+	var header = make([]cell, len(columns))
+	for i, column := range columns {
+		header[i] = cell{text: column}
+	}
+
+	io.WriteString(out, border.String())
+	writeRow(header)
+	io.WriteString(out, border.String())
+	for _, row := range rows {
+		writeRow(row)
+	}
+	if len(rows) > 0 {
+		io.WriteString(out, border.String())
+	}
+
+	switch len(rows) {
+	case 0:
+		io.WriteString(out, "Empty set\n")
+	case 1:
+		io.WriteString(out, "1 row in set\n")
+	default:
+		fmt.Fprintf(out, "%d rows in set\n", len(rows))
+	}
+}
@@ -0,0 +1,82 @@
+package elasticsql
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// handleDelete translates delete to a _delete_by_query request
+// the where is translated the same as select, limit is max_docs
+func handleDelete(del *sqlparser.Delete, opts *Options) (*Statement, error) {
+	if len(del.Targets) > 0 || len(del.TableExprs) != 1 {
+		return nil, unsupportedError("table", sqlparser.String(del.TableExprs), "delete from multiple tables is not supported")
+	}
+	esType := strings.Replace(sqlparser.String(del.TableExprs), "`", "", -1)
+
+	// the _delete_by_query of 2.x is a plugin with another endpoint
+	if opts.TargetVersion == Elasticsearch2 {
+		return nil, unsupportedError("statement", sqlparser.String(del), "delete is not supported by elasticsearch 2.x")
+	}
+
+	if len(del.OrderBy) > 0 {
+		return nil, unsupportedError("clause", sqlparser.String(del.OrderBy), "order by in delete is not supported")
+	}
+
+	var req = deleteByQueryRequest{}
+	if del.Where != nil {
+		var err error
+		req.Query, err = handleSelectWhere(&del.Where.Expr, true, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// delete without where removes all the documents
+		if !opts.AllowUnconditionalDelete {
+			return nil, unsupportedError("statement", sqlparser.String(del), "delete without where is refused, set AllowUnconditionalDelete to allow it")
+		}
+		req.Query = &boolQuery{must: []query{&matchAllQuery{}}}
+	}
+
+	if del.Limit != nil {
+		var err error
+		var maxDocs int
+		maxDocs, err = buildMaxDocs(del.Limit)
+		if err != nil {
+			return nil, err
+		}
+		if opts.maxDocsKey() == "size" {
+			req.Size = maxDocs
+		} else {
+			req.MaxDocs = maxDocs
+		}
+	}
+
+	dslBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Statement{
+		DSL:      string(dslBytes),
+		Table:    esType,
+		Endpoint: DeleteByQueryEndpoint,
+	}, nil
+}
+
+// buildMaxDocs returns the max_docs of the by query requests from limit
+func buildMaxDocs(limit *sqlparser.Limit) (int, error) {
+	if limit.Offset != nil {
+		return 0, unsupportedError("limit", sqlparser.String(limit), "offset is only supported by select")
+	}
+	maxDocs, err := buildLimitValue(limit.Rowcount)
+	if err != nil {
+		return 0, err
+	}
+	// max_docs 0 means no limit, while limit 0 means none
+	if maxDocs <= 0 {
+		return 0, valueError("limit", sqlparser.String(limit.Rowcount), "limit must be greater than 0")
+	}
+	return maxDocs, nil
+}
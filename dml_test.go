@@ -0,0 +1,78 @@
+package elasticsql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// the cases of delete and update, which are sent to the by query apis
+var dmlCaseList = []struct {
+	endpoint string
+	sql      string
+	opts     Options
+	dsl      string
+}{
+	{DeleteByQueryEndpoint, "delete from a where id=1", Options{}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"id" : {"query" : 1}}}]}}}`},
+	{DeleteByQueryEndpoint, "delete from `order` where status = 'closed' and create_time < '2020-01-01' limit 1000", Options{Equality: TermEquality}, `{"query" : {"bool" : {"must" : [{"term" : {"status" : "closed"}},{"range" : {"create_time" : {"lt" : "2020-01-01"}}}]}},"max_docs" : 1000}`},
+	{DeleteByQueryEndpoint, "delete from a where id in ::ids", Options{NamedParams: map[string]interface{}{"ids": []int{1, 2}}}, `{"query" : {"bool" : {"must" : [{"terms" : {"id" : [1,2]}}]}}}`},
+	{DeleteByQueryEndpoint, "delete from a", Options{AllowUnconditionalDelete: true}, `{"query" : {"bool" : {"must" : [{"match_all" : {}}]}}}`},
+	{UpdateByQueryEndpoint, "update idx set status = 2, retries = retries + 1 where id = 1 limit 10", Options{}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"id" : {"query" : 1}}}]}},"script" : {"lang" : "painless","params" : {"p0" : 2,"p1" : 1},"source" : "ctx._source['status'] = params.p0; ctx._source['retries'] = ctx._source['retries'] == null ? null : ctx._source['retries'] + params.p1"},"max_docs" : 10}`},
+	{UpdateByQueryEndpoint, "update idx set user.name = concat(user.first, ' ', user.last), deleted_at = null", Options{}, `{"query" : {"bool" : {"must" : [{"match_all" : {}}]}},"script" : {"lang" : "painless","params" : {"p0" : " ","p1" : null},"source" : "if (ctx._source['user'] == null) { ctx._source['user'] = new HashMap(); } ctx._source['user']['name'] = ctx._source['user']?.get('first') == null || ctx._source['user']?.get('last') == null ? null : \"\" + ctx._source['user']?.get('first') + params.p0 + ctx._source['user']?.get('last'); ctx._source['deleted_at'] = params.p1"}}`},
+	{UpdateByQueryEndpoint, "update idx set name = ? where id in ::ids", Options{Params: []interface{}{"x'y"}, NamedParams: map[string]interface{}{"ids": []int{1, 2}}}, `{"query" : {"bool" : {"must" : [{"terms" : {"id" : [1,2]}}]}},"script" : {"lang" : "painless","params" : {"p0" : "x'y"},"source" : "ctx._source['name'] = params.p0"}}`},
+	{UpdateByQueryEndpoint, "update idx set `a']['op` = 'delete', n = `x'].value + ctx['y` where id = 1", Options{}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"id" : {"query" : 1}}}]}},"script" : {"lang" : "painless","params" : {"p0" : "delete"},"source" : "ctx._source['a\\'][\\'op'] = params.p0; ctx._source['n'] = ctx._source['x\\'].value + ctx[\\'y'] == null ? null : ctx._source['x\\'].value + ctx[\\'y']"}}`},
+	{UpdateByQueryEndpoint, "update idx set `a.b` = 1, a.b = 2, x.y.z = `a.b`", Options{}, `{"query" : {"bool" : {"must" : [{"match_all" : {}}]}},"script" : {"lang" : "painless","params" : {"p0" : 1,"p1" : 2},"source" : "ctx._source['a.b'] = params.p0; if (ctx._source['a'] == null) { ctx._source['a'] = new HashMap(); } ctx._source['a']['b'] = params.p1; if (ctx._source['x'] == null) { ctx._source['x'] = new HashMap(); } if (ctx._source['x']['y'] == null) { ctx._source['x']['y'] = new HashMap(); } ctx._source['x']['y']['z'] = ctx._source['a.b'] == null ? null : ctx._source['a.b']"}}`},
+}
+
+var dmlUnsupportedList = []string{
+	"delete from a",
+	"delete from a where id = 1 order by id",
+	"delete from a where id = 1 limit 1, 2",
+	"delete from a where id = 1 limit 0",
+	"delete a, b from a join b on a.id = b.id where a.id = 1",
+	"update idx set x = 1 order by id",
+	"update idx set x = 1 limit 1, 2",
+	"update idx set x = 1 limit 0",
+	"update a, b set x = 1",
+	"update idx set x = a & b",
+}
+
+func TestDML(t *testing.T) {
+	for _, c := range dmlCaseList {
+		stmt, err := ConvertStatement(c.sql, c.opts)
+		if err != nil {
+			t.Error("convert failed", c.sql, err)
+			continue
+		}
+		if stmt.Endpoint != c.endpoint {
+			t.Error("the endpoint is not equal to expected", c.sql, stmt.Endpoint)
+		}
+
+		var dslMap, dslConvertedMap map[string]interface{}
+		err = json.Unmarshal([]byte(c.dsl), &dslMap)
+		if err != nil {
+			t.Error("test case json unmarshal err!", c.sql)
+		}
+		err = json.Unmarshal([]byte(stmt.DSL), &dslConvertedMap)
+		if err != nil {
+			t.Error("the generated dsl json unmarshal error!", c.sql)
+		}
+		if !reflect.DeepEqual(dslMap, dslConvertedMap) {
+			t.Error("the generated dsl is not equal to expected", c.sql, stmt.DSL)
+		}
+	}
+
+	for _, v := range dmlUnsupportedList {
+		_, _, err := Convert(v)
+		if err == nil {
+			t.Error("can not be true, these cases are not supported!", v)
+		}
+	}
+
+	for k, v := range map[string]string{"delete from `order` where id = 1": "order", "update idx set x = 1": "idx"} {
+		_, table, _ := Convert(k)
+		if table != v {
+			t.Error("the table is not equal to expected", k, table)
+		}
+	}
+}
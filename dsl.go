@@ -0,0 +1,328 @@
+package elasticsql
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// query is a node of the elasticsearch query dsl
+// every node marshals itself to the json the server expects
+type query interface {
+	json.Marshaler
+	iQuery()
+}
+
+func (*matchAllQuery) iQuery()    {}
+func (*boolQuery) iQuery()        {}
+func (*matchPhraseQuery) iQuery() {}
+func (*termQuery) iQuery()        {}
+func (*termsQuery) iQuery()       {}
+func (*rangeQuery) iQuery()       {}
+func (*existsQuery) iQuery()      {}
+func (*multiMatchQuery) iQuery()  {}
+func (*prefixQuery) iQuery()      {}
+func (*wildcardQuery) iQuery()    {}
+func (*regexpQuery) iQuery()      {}
+func (*scriptQuery) iQuery()      {}
+
+// bool operators recorded in boolQuery.op
+const (
+	andOp = "and"
+	orOp  = "or"
+)
+
+type matchAllQuery struct{}
+
+// MarshalJSON implements json.Marshaler
+func (q *matchAllQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"match_all": msi{}})
+}
+
+type boolQuery struct {
+	must    []query
+	should  []query
+	mustNot []query
+
+	// op is the sql operator this node was built from
+	// children of and/or with the same op can be merged into the parent
+	op string
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *boolQuery) MarshalJSON() ([]byte, error) {
+	var clauses = make(msi)
+	if len(q.must) > 0 {
+		clauses["must"] = q.must
+	}
+	if len(q.should) > 0 {
+		clauses["should"] = q.should
+	}
+	if len(q.mustNot) > 0 {
+		clauses["must_not"] = q.mustNot
+	}
+	return json.Marshal(msi{"bool": clauses})
+}
+
+type matchPhraseQuery struct {
+	field string
+	query interface{}
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *matchPhraseQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"match_phrase": msi{q.field: msi{"query": q.query}}})
+}
+
+type termQuery struct {
+	field string
+	value interface{}
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *termQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"term": msi{q.field: q.value}})
+}
+
+type termsQuery struct {
+	field  string
+	values []interface{}
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *termsQuery) MarshalJSON() ([]byte, error) {
+	values := q.values
+	if values == nil {
+		values = []interface{}{}
+	}
+	return json.Marshal(msi{"terms": msi{q.field: values}})
+}
+
+type rangeQuery struct {
+	field string
+	// bounds is keyed by from/to/gt/lt
+	bounds msi
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *rangeQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"range": msi{q.field: q.bounds}})
+}
+
+type existsQuery struct {
+	field string
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *existsQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"exists": msi{"field": q.field}})
+}
+
+type prefixQuery struct {
+	field  string
+	prefix string
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *prefixQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"prefix": msi{q.field: msi{"value": q.prefix}}})
+}
+
+type wildcardQuery struct {
+	field string
+	// pattern uses * and ?, the literal *, ? and \ are escaped with \
+	pattern string
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *wildcardQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"wildcard": msi{q.field: msi{"value": q.pattern}}})
+}
+
+type regexpQuery struct {
+	field   string
+	pattern string
+	flags   string
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *regexpQuery) MarshalJSON() ([]byte, error) {
+	params := msi{"value": q.pattern}
+	if q.flags != "" {
+		params["flags"] = q.flags
+	}
+	return json.Marshal(msi{"regexp": msi{q.field: params}})
+}
+
+type multiMatchQuery struct {
+	query  string
+	typ    string
+	fields []string
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *multiMatchQuery) MarshalJSON() ([]byte, error) {
+	params := msi{
+		"query":  q.query,
+		"fields": q.fields,
+	}
+	if q.typ != "" {
+		params["type"] = q.typ
+	}
+	return json.Marshal(msi{"multi_match": params})
+}
+
+type scriptQuery struct {
+	script *script
+}
+
+// MarshalJSON implements json.Marshaler
+func (q *scriptQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{"script": msi{"script": q.script}})
+}
+
+// aggregation is a named node of the aggregation tree
+// kind is the aggregation type, eg. terms, date_histogram, value_count
+type aggregation struct {
+	name     string
+	kind     string
+	params   msi
+	children aggregations
+}
+
+// MarshalJSON implements json.Marshaler
+func (agg *aggregation) MarshalJSON() ([]byte, error) {
+	var body = msi{agg.kind: agg.params}
+	if len(agg.children) > 0 {
+		body["aggregations"] = agg.children
+	}
+	return json.Marshal(body)
+}
+
+// aggregations keeps the sibling aggregations in the order they appear in sql
+type aggregations []*aggregation
+
+// add appends agg if there is no sibling with the same name yet
+func (aggs aggregations) add(agg *aggregation) aggregations {
+	for _, sibling := range aggs {
+		if sibling.name == agg.name {
+			return aggs
+		}
+	}
+	return append(aggs, agg)
+}
+
+// MarshalJSON implements json.Marshaler
+func (aggs aggregations) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, agg := range aggs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(agg.name)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(agg)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(body)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// script is a painless script with its params
+type script struct {
+	source string
+	params msi
+	// sourceKey is the key of the source, inline before 6.x
+	sourceKey string
+}
+
+// MarshalJSON implements json.Marshaler
+func (s *script) MarshalJSON() ([]byte, error) {
+	body := msi{
+		"lang":      "painless",
+		s.sourceKey: s.source,
+	}
+	if len(s.params) > 0 {
+		body["params"] = s.params
+	}
+	return json.Marshal(body)
+}
+
+type sortField struct {
+	field string
+	order string
+}
+
+// MarshalJSON implements json.Marshaler
+func (s sortField) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msi{s.field: s.order})
+}
+
+// searchRequest is the body of a _search request
+type searchRequest struct {
+	Query           query        `json:"query"`
+	From            int          `json:"from"`
+	Size            int          `json:"size"`
+	Source          interface{}  `json:"_source,omitempty"`
+	DocValueFields  []string     `json:"docvalue_fields,omitempty"`
+	StoredFields    []string     `json:"stored_fields,omitempty"`
+	FielddataFields []string     `json:"fielddata_fields,omitempty"`
+	Fields          []string     `json:"fields,omitempty"`
+	ScriptFields    msi          `json:"script_fields,omitempty"`
+	Sort            []sortField  `json:"sort,omitempty"`
+	Aggregations    aggregations `json:"aggregations,omitempty"`
+}
+
+// updateByQueryRequest is the body of a _update_by_query request
+type updateByQueryRequest struct {
+	Query   query   `json:"query"`
+	Script  *script `json:"script"`
+	MaxDocs int     `json:"max_docs,omitempty"`
+	// Size is max_docs before 7.3
+	Size int `json:"size,omitempty"`
+}
+
+// bulkDocument is the source of a bulk action, the fields keep the order of the columns
+type bulkDocument struct {
+	fields []string
+	values []interface{}
+}
+
+// MarshalJSON implements json.Marshaler
+func (doc bulkDocument) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range doc.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(doc.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// deleteByQueryRequest is the body of a _delete_by_query request
+type deleteByQueryRequest struct {
+	Query   query `json:"query"`
+	MaxDocs int   `json:"max_docs,omitempty"`
+	// Size is max_docs before 7.3
+	Size int `json:"size,omitempty"`
+}
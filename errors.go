@@ -0,0 +1,121 @@
+package elasticsql
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrorCode tells why the translation failed
+type ErrorCode int
+
+const (
+	// ErrCodeUnknown is the code of the errors not returned by the translation
+	ErrCodeUnknown ErrorCode = iota
+	// ErrCodeSyntax means the sql can not be parsed
+	ErrCodeSyntax
+	// ErrCodeUnsupported means the sql is valid, but there is no dsl for it
+	ErrCodeUnsupported
+	// ErrCodeInvalidValue means a value of the sql has the wrong type or format, eg. limit 'x'
+	ErrCodeInvalidValue
+)
+
+// SyntaxError is returned when the sql can not be parsed
+type SyntaxError struct {
+	// Position is the byte offset in the sql where the parser stopped, 0 if unknown
+	Position int
+	// Near is the token at the position
+	Near string
+	// Err is the error of the parser
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return "elasticsql: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the parser
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns ErrCodeSyntax
+func (e *SyntaxError) Code() ErrorCode {
+	return ErrCodeSyntax
+}
+
+// UnsupportedError is returned when the sql is valid, but can not be translated
+type UnsupportedError struct {
+	// Node is the kind of the sql node, eg. statement, expression, function, operator
+	Node string
+	// Fragment is the sql of the node
+	Fragment string
+	// Reason tells what is not supported
+	Reason string
+}
+
+func (e *UnsupportedError) Error() string {
+	return buildErrorMessage(e.Reason, e.Fragment)
+}
+
+// Code returns ErrCodeUnsupported
+func (e *UnsupportedError) Code() ErrorCode {
+	return ErrCodeUnsupported
+}
+
+// ValueError is returned when a value of the sql has the wrong type or format
+type ValueError struct {
+	// Node is the kind of the sql node, eg. limit, pattern, number
+	Node string
+	// Fragment is the sql of the value
+	Fragment string
+	// Reason tells what the value should be
+	Reason string
+}
+
+func (e *ValueError) Error() string {
+	return buildErrorMessage(e.Reason, e.Fragment)
+}
+
+// Code returns ErrCodeInvalidValue
+func (e *ValueError) Code() ErrorCode {
+	return ErrCodeInvalidValue
+}
+
+func buildErrorMessage(reason, fragment string) string {
+	if fragment == "" {
+		return "elasticsql: " + reason
+	}
+	return "elasticsql: " + reason + ", got " + fragment
+}
+
+// ErrorCodeOf returns the code of the error returned by the translation
+func ErrorCodeOf(err error) ErrorCode {
+	var coded interface{ Code() ErrorCode }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ErrCodeUnknown
+}
+
+// the clauses like limit and order by are formatted with a leading space by sqlparser
+func unsupportedError(node, fragment, reason string) error {
+	return &UnsupportedError{Node: node, Fragment: strings.TrimSpace(fragment), Reason: reason}
+}
+
+func valueError(node, fragment, reason string) error {
+	return &ValueError{Node: node, Fragment: strings.TrimSpace(fragment), Reason: reason}
+}
+
+// the error of sqlparser is like: syntax error at position 22 near 'from'
+var syntaxErrorPattern = regexp.MustCompile(`at position (\d+)(?: near '(.*)')?`)
+
+func newSyntaxError(err error) error {
+	syntaxErr := &SyntaxError{Err: err}
+	if match := syntaxErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		syntaxErr.Position, _ = strconv.Atoi(match[1])
+		syntaxErr.Near = match[2]
+	}
+	return syntaxErr
+}
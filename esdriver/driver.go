@@ -0,0 +1,212 @@
+// Package esdriver is a database/sql driver which translates sql with elasticsql
+// and sends the dsl to the http api of elasticsearch
+//
+//	db, err := sql.Open("elasticsql", "http://localhost:9200?target=es7")
+//	rows, err := db.Query("select region, count(*) from orders where status = ? group by region", "paid")
+//
+// the data source name is the url of elasticsearch, the user and password in the url are sent as basic auth,
+// the target param is the target version of the dsl, eg. es2, es5, es6, es7, es8, opensearch,
+// the type param is the _type of the inserted documents before 7.x, the other params are sent
+// to elasticsearch with every request, eg. http://localhost:9200?target=es7&refresh=true
+package esdriver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/cch123/elasticsql"
+)
+
+// DriverName is the name of the driver registered to database/sql
+const DriverName = "elasticsql"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver is the database/sql driver of elasticsql
+type Driver struct{}
+
+// Open opens a connection to the elasticsearch of the url
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector parses the url of elasticsearch, see the package doc for the params
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	var opts elasticsql.Options
+	query := u.Query()
+	if target := query.Get("target"); target != "" {
+		opts.TargetVersion, err = elasticsql.ParseVersion(target)
+		if err != nil {
+			return nil, err
+		}
+	}
+	opts.DocType = query.Get("type")
+	query.Del("target")
+	query.Del("type")
+	u.RawQuery = query.Encode()
+	return NewConnector(u.String(), opts, nil), nil
+}
+
+// NewConnector returns the connector for sql.OpenDB, opts are used to translate all the sql,
+// the params of the queries are added to opts, client is http.DefaultClient if it is nil
+// the query of the url is sent with every request, eg. http://localhost:9200?timeout=1m
+func NewConnector(url string, opts elasticsql.Options, client *http.Client) driver.Connector {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var query string
+	if i := strings.IndexByte(url, '?'); i >= 0 {
+		url, query = url[:i], url[i:]
+	}
+	return &connector{url: strings.TrimSuffix(url, "/"), query: query, opts: opts, client: client}
+}
+
+type connector struct {
+	// url is the url of elasticsearch without the query, the path of the api is appended to it
+	url    string
+	query  string
+	opts   elasticsql.Options
+	client *http.Client
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// conn is stateless, every query is a http request
+type conn struct {
+	*connector
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("esdriver: transactions are not supported")
+}
+
+// CheckNamedValue accepts the slices for the list placeholders, eg. id in ::ids
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nv.Value != nil && reflect.TypeOf(nv.Value).Kind() == reflect.Slice {
+		if _, ok := nv.Value.([]byte); !ok {
+			return nil
+		}
+	}
+	var err error
+	nv.Value, err = driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	return err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := c.convert(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Endpoint != elasticsql.SearchEndpoint {
+		return nil, errors.New("esdriver: only select can be queried, use exec for " + stmt.Endpoint)
+	}
+
+	body, err := c.do(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	result, err := elasticsql.ConvertResponse(stmt, body)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{result: result}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmt, err := c.convert(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Endpoint == elasticsql.SearchEndpoint {
+		return nil, errors.New("esdriver: select can not be executed, use query instead")
+	}
+
+	body, err := c.do(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	return buildResult(stmt, body)
+}
+
+// convert translates the query with the args as the params of the placeholders
+func (c *conn) convert(query string, args []driver.NamedValue) (*elasticsql.Statement, error) {
+	opts := c.opts
+	opts.Params = nil
+	opts.NamedParams = make(map[string]interface{}, len(c.opts.NamedParams))
+	for k, v := range c.opts.NamedParams {
+		opts.NamedParams[k] = v
+	}
+	for _, arg := range args {
+		if arg.Name != "" {
+			opts.NamedParams[arg.Name] = arg.Value
+		} else {
+			opts.Params = append(opts.Params, arg.Value)
+		}
+	}
+	return elasticsql.ConvertStatement(query, opts)
+}
+
+// do sends the dsl of the statement, and returns the body of the response
+func (c *conn) do(ctx context.Context, stmt *elasticsql.Statement) ([]byte, error) {
+	contentType := "application/json"
+	if stmt.Endpoint == elasticsql.BulkEndpoint {
+		contentType = "application/x-ndjson"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url+"/"+url.PathEscape(stmt.Table)+"/"+stmt.Endpoint+c.query, strings.NewReader(stmt.DSL))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		if err := elasticsql.CheckResponse(body); err != nil {
+			return nil, err
+		}
+		return nil, &elasticsql.ResponseError{Status: resp.StatusCode, Reason: string(bytes.TrimSpace(body))}
+	}
+	return body, nil
+}
@@ -0,0 +1,166 @@
+package esdriver
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cch123/elasticsql"
+	"github.com/cch123/elasticsql/internal/estest"
+)
+
+// the canned responses of the paths
+var responseMap = map[string]string{
+	"/orders/_search":          `{"hits":{"hits":[]},"aggregations":{"region":{"buckets":[{"key":"bj","doc_count":2,"total":{"value":2}},{"key":"sh","doc_count":1,"total":{"value":1}}]}}}`,
+	"/users/_search":           `{"hits":{"hits":[{"_id":"1","_source":{"id":1,"name":"x","tags":["a","b"]}}]}}`,
+	"/orders/_delete_by_query": `{"took":1,"deleted":3}`,
+	"/orders/_bulk":            `{"errors":false,"items":[{"create":{"_id":"1","status":201}},{"create":{"_id":"2","status":201}}]}`,
+	"/users/_bulk":             `{"errors":true,"items":[{"create":{"_id":"1","status":409,"error":{"type":"version_conflict_engine_exception","reason":"document already exists"}}}]}`,
+}
+
+func TestQuery(t *testing.T) {
+	var bodies = make(map[string]string)
+	server := estest.NewServer(responseMap, bodies)
+	defer server.Close()
+
+	db, err := sql.Open(DriverName, server.URL+"?target=es7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("select region, count(*) as total from orders where status = ? group by region", "paid")
+	if err != nil {
+		t.Fatal("query failed", err)
+	}
+	columns, _ := rows.Columns()
+	if !reflect.DeepEqual(columns, []string{"region", "total"}) {
+		t.Error("the columns are not equal to expected", columns)
+	}
+	var results = make(map[string]int64)
+	for rows.Next() {
+		var region string
+		var total int64
+		if err := rows.Scan(&region, &total); err != nil {
+			t.Error("scan failed", err)
+		}
+		results[region] = total
+	}
+	rows.Close()
+	if !reflect.DeepEqual(results, map[string]int64{"bj": 2, "sh": 1}) {
+		t.Error("the rows are not equal to expected", results)
+	}
+
+	dsl, _, _ := elasticsql.ConvertWithOptions("select region, count(*) as total from orders where status = 'paid' group by region", elasticsql.Options{TargetVersion: elasticsql.Elasticsearch7})
+	if bodies["/orders/_search"] != dsl {
+		t.Error("the dsl sent is not equal to expected", bodies["/orders/_search"])
+	}
+
+	var id int64
+	var name, tags string
+	err = db.QueryRow("select id, name, tags from users where id in ::ids", sql.Named("ids", []int{1, 2})).Scan(&id, &name, &tags)
+	if err != nil || id != 1 || name != "x" || tags != `["a","b"]` {
+		t.Error("the row is not equal to expected", id, name, tags, err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	server := estest.NewServer(responseMap, nil)
+	defer server.Close()
+
+	// the query of the url is sent after the path of the api
+	db := sql.OpenDB(NewConnector(server.URL+"/?refresh=true", elasticsql.Options{}, server.Client()))
+	defer db.Close()
+
+	result, err := db.Exec("delete from orders where status = ?", "closed")
+	if err != nil {
+		t.Fatal("exec failed", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 3 {
+		t.Error("the rows affected are not equal to expected", affected)
+	}
+
+	result, err = db.Exec("insert into orders (_id, status) values (1, 'paid'), (2, 'paid')")
+	if err != nil {
+		t.Fatal("exec failed", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 2 {
+		t.Error("the rows affected are not equal to expected", affected)
+	}
+
+	_, err = db.Exec("insert into users (_id, name) values (1, 'x')")
+	var responseErr *elasticsql.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.Type != "version_conflict_engine_exception" {
+		t.Error("the error of bulk is not equal to expected", err)
+	}
+
+	// the existing documents are kept by insert ignore
+	result, err = db.Exec("insert ignore into users (_id, name) values (1, 'x')")
+	if err != nil {
+		t.Fatal("exec failed", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 0 {
+		t.Error("the rows affected are not equal to expected", affected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := estest.NewServer(responseMap, nil)
+	defer server.Close()
+
+	db, _ := sql.Open(DriverName, server.URL)
+	defer db.Close()
+
+	_, err := db.Query("select * from a where a <=> 1")
+	if elasticsql.ErrorCodeOf(err) != elasticsql.ErrCodeUnsupported {
+		t.Error("the error of unsupported statement is not equal to expected", err)
+	}
+
+	_, err = db.Query("select * from missing")
+	var responseErr *elasticsql.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.Status != 404 {
+		t.Error("the error of elasticsearch is not equal to expected", err)
+	}
+
+	if _, err = db.Query("delete from orders where id = 1"); err == nil {
+		t.Error("can not be true, delete can not be queried")
+	}
+	if _, err = db.Exec("select * from orders"); err == nil {
+		t.Error("can not be true, select can not be executed")
+	}
+	if _, err = db.Begin(); err == nil {
+		t.Error("can not be true, transactions are not supported")
+	}
+	if _, err = sql.Open(DriverName, "http://localhost:9200?target=es9"); err == nil {
+		t.Error("can not be true, es9 is not a version")
+	}
+	if _, err = sql.Open(DriverName, "http://localhost:9200?target=es5&type=doc"); err != nil {
+		t.Error("open with the doc type failed", err)
+	}
+}
+
+func TestOpenParams(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(responseMap["/orders/_delete_by_query"]))
+	}))
+	defer server.Close()
+
+	// target and type are used by the driver, the other params are sent to elasticsearch
+	db, err := sql.Open(DriverName, server.URL+"?target=es7&refresh=true&type=doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("delete from orders where id = 1"); err != nil {
+		t.Fatal("exec failed", err)
+	}
+	if query != "refresh=true" {
+		t.Error("the query of the request is not equal to expected", query)
+	}
+}
@@ -0,0 +1,99 @@
+package esdriver
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/cch123/elasticsql"
+)
+
+// rows reads the rows of the converted response
+type rows struct {
+	result *elasticsql.ResultSet
+	index  int
+}
+
+func (r *rows) Columns() []string {
+	return r.result.Columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.index >= len(r.result.Rows) {
+		return io.EOF
+	}
+	for i, val := range r.result.Rows[r.index] {
+		switch val.(type) {
+		case map[string]interface{}, []interface{}:
+			// the objects and arrays are scanned as json
+			b, err := json.Marshal(val)
+			if err != nil {
+				return err
+			}
+			dest[i] = b
+		default:
+			dest[i] = val
+		}
+	}
+	r.index++
+	return nil
+}
+
+// result is the number of the documents changed by delete, update and insert
+type result struct {
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, errors.New("esdriver: last insert id is not supported")
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// buildResult reads the number of the changed documents from the response,
+// the first failed action of bulk is returned as the error
+func buildResult(stmt *elasticsql.Statement, body []byte) (driver.Result, error) {
+	if stmt.Endpoint != elasticsql.BulkEndpoint {
+		var resp struct {
+			Deleted int64 `json:"deleted"`
+			Updated int64 `json:"updated"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		return result{rowsAffected: resp.Deleted + resp.Updated}, nil
+	}
+
+	var resp struct {
+		Items []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	var affected int64
+	for _, item := range resp.Items {
+		for _, action := range item {
+			// insert ignore keeps the existing documents, they are not affected
+			if stmt.Ignore && action.Status == http.StatusConflict {
+				continue
+			}
+			if action.Error != nil {
+				errBody, _ := json.Marshal(map[string]interface{}{"error": action.Error, "status": action.Status})
+				return nil, elasticsql.CheckResponse(errBody)
+			}
+			affected++
+		}
+	}
+	return result{rowsAffected: affected}, nil
+}
@@ -0,0 +1,99 @@
+package elasticsql
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// the column which is the id of the document instead of a field
+const idColumn = "_id"
+
+// handleInsert translates insert to the ndjson of a _bulk request
+// insert and insert ignore create the documents, so the existing ones are kept,
+// replace indexes the documents, so the existing ones are overwritten
+func handleInsert(ins *sqlparser.Insert, opts *Options) (*Statement, error) {
+	esType := strings.Replace(sqlparser.String(ins.Table), "`", "", -1)
+
+	if len(ins.Columns) == 0 {
+		return nil, unsupportedError("statement", sqlparser.String(ins), "insert without column list is not supported")
+	}
+	if len(ins.OnDup) > 0 {
+		return nil, unsupportedError("clause", sqlparser.String(ins.OnDup), "on duplicate key update is not supported")
+	}
+	rows, ok := ins.Rows.(sqlparser.Values)
+	if !ok {
+		return nil, unsupportedError("clause", sqlparser.String(ins.Rows), "only insert ... values is supported")
+	}
+
+	// the actions need the _type of the documents before 7.x, which is _doc by default since 6.x
+	var docType string
+	switch opts.TargetVersion {
+	case Elasticsearch2, Elasticsearch5:
+		if opts.DocType == "" {
+			return nil, unsupportedError("statement", sqlparser.String(ins), "insert needs the doc type before elasticsearch 6.x")
+		}
+		docType = opts.DocType
+	case Elasticsearch6:
+		docType = opts.DocType
+		if docType == "" {
+			docType = "_doc"
+		}
+	}
+
+	var opType = "create"
+	if ins.Action == sqlparser.ReplaceStr {
+		opType = "index"
+	}
+
+	var fields = make([]string, len(ins.Columns))
+	for i, column := range ins.Columns {
+		fields[i] = column.String()
+	}
+
+	var buf bytes.Buffer
+	for _, row := range rows {
+		if len(row) != len(fields) {
+			return nil, valueError("row", sqlparser.String(row), "the number of values must be the same as the columns")
+		}
+
+		var action = msi{"_index": esType}
+		if docType != "" {
+			action["_type"] = docType
+		}
+		var doc = bulkDocument{}
+		for i, valExpr := range row {
+			val, err := buildValue(valExpr)
+			if err != nil {
+				return nil, err
+			}
+			if fields[i] == idColumn {
+				// null id lets elasticsearch generate one
+				if val != nil {
+					action["_id"] = buildValueStr(val)
+				}
+				continue
+			}
+			doc.fields = append(doc.fields, fields[i])
+			doc.values = append(doc.values, val)
+		}
+
+		for _, line := range []interface{}{msi{opType: action}, doc} {
+			lineBytes, err := json.Marshal(line)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(lineBytes)
+			buf.WriteByte('\n')
+		}
+	}
+
+	return &Statement{
+		DSL:      buf.String(),
+		Table:    esType,
+		Endpoint: BulkEndpoint,
+		Ignore:   ins.Ignore != "",
+	}, nil
+}
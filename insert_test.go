@@ -0,0 +1,73 @@
+package elasticsql
+
+import (
+	"strings"
+	"testing"
+)
+
+var insertCaseMap = map[string]string{
+	"insert into idx (_id, a, b) values (1, 'x', 1.5), (null, true, null)": `{"create":{"_id":"1","_index":"idx"}}
+{"a":"x","b":1.5}
+{"create":{"_index":"idx"}}
+{"a":true,"b":null}
+`,
+	"insert ignore into idx (a, `b`, `user.name`) values ('it''s', -2, x'616263')": `{"create":{"_index":"idx"}}
+{"a":"it's","b":-2,"user.name":"abc"}
+`,
+	"replace into idx (_id, a) values ('k', 'v')": `{"index":{"_id":"k","_index":"idx"}}
+{"a":"v"}
+`,
+}
+
+var insertUnsupportedList = []string{
+	"insert into idx values (1, 2)",
+	"insert into idx (a) values (1, 2)",
+	"insert into idx (a) select a from b",
+	"insert into idx (a) values (1) on duplicate key update a = 2",
+	"insert into idx (a) values (b)",
+}
+
+func TestInsert(t *testing.T) {
+	for k, v := range insertCaseMap {
+		stmt, err := ConvertStatement(k, Options{})
+		if err != nil {
+			t.Error("convert insert failed", k, err)
+			continue
+		}
+		if stmt.DSL != v {
+			t.Error("the generated ndjson is not equal to expected", k, stmt.DSL)
+		}
+		if stmt.Ignore != strings.HasPrefix(k, "insert ignore") {
+			t.Error("the ignore of insert is not equal to expected", k, stmt.Ignore)
+		}
+		if stmt.Endpoint != BulkEndpoint || stmt.Table != "idx" {
+			t.Error("the endpoint or table of insert is not equal to expected", k, stmt.Endpoint, stmt.Table)
+		}
+
+		// ndjson is not prettified
+		dsl, _, err := ConvertPretty(k)
+		if err != nil || dsl != v {
+			t.Error("the pretty ndjson is not equal to expected", k, dsl, err)
+		}
+	}
+
+	dsl, _, err := ConvertWithOptions("insert into idx (a, b) values (?, :b)", Options{Params: []interface{}{"x"}, NamedParams: map[string]interface{}{"b": 2}})
+	if err != nil || dsl != "{\"create\":{\"_index\":\"idx\"}}\n{\"a\":\"x\",\"b\":2}\n" {
+		t.Error("the ndjson with params is not equal to expected", dsl, err)
+	}
+
+	// the type of the mapping of the index before 7.x
+	for _, version := range []Version{Elasticsearch2, Elasticsearch5, Elasticsearch6} {
+		dsl, _, err = ConvertWithOptions("insert into idx (a) values (1)", Options{TargetVersion: version, DocType: "doc"})
+		if err != nil || dsl != "{\"create\":{\"_index\":\"idx\",\"_type\":\"doc\"}}\n{\"a\":1}\n" {
+			t.Error("the ndjson with doc type is not equal to expected", version, dsl, err)
+		}
+	}
+
+	for _, v := range insertUnsupportedList {
+		_, _, err := Convert(v)
+		if err == nil {
+			t.Error("can not be true, these cases are not supported!", v)
+		}
+	}
+}
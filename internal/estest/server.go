@@ -0,0 +1,30 @@
+// Package estest is the fake elasticsearch of the tests of the driver and the command
+package estest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+)
+
+// NotFoundResponse is the response of the paths without a canned response
+const NotFoundResponse = `{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index"}],"type":"index_not_found_exception","reason":"no such index"},"status":404}`
+
+// NewServer returns a server which answers the requests with the canned responses of the paths,
+// the bodies of the requests are recorded by the paths if bodies is not nil
+func NewServer(responses map[string]string, bodies map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bodies != nil {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies[r.URL.Path] = string(body)
+		}
+
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(NotFoundResponse))
+			return
+		}
+		w.Write([]byte(response))
+	}))
+}
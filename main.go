@@ -4,49 +4,101 @@ import (
 	"bytes"
 
 	"encoding/json"
+	"strings"
 
 	"github.com/xwb1989/sqlparser"
 )
 
 // ConvertPretty will transform sql to elasticsearch dsl, and prettify the output json
 func ConvertPretty(sql string) (dsl string, table string, err error) {
-	dsl, table, err = Convert(sql)
+	return ConvertPrettyWithOptions(sql, Options{})
+}
+
+// ConvertPrettyWithOptions is ConvertPretty with the translation controlled by opts
+func ConvertPrettyWithOptions(sql string, opts Options) (dsl string, table string, err error) {
+	stmt, err := ConvertStatement(sql, opts)
 	if err != nil {
-		return dsl, table, err
+		return "", "", err
+	}
+
+	// ndjson must keep one json in one line
+	if stmt.Endpoint == BulkEndpoint {
+		return stmt.DSL, stmt.Table, nil
 	}
 
 	var prettifiedDSLBytes bytes.Buffer
-	err = json.Indent(&prettifiedDSLBytes, []byte(dsl), "", "  ")
+	err = json.Indent(&prettifiedDSLBytes, []byte(stmt.DSL), "", "  ")
 	if err != nil {
-		return "", table, err
+		return "", stmt.Table, err
 	}
 
-	return prettifiedDSLBytes.String(), table, err
+	return prettifiedDSLBytes.String(), stmt.Table, err
 }
 
 // Convert will transform sql to elasticsearch dsl string
 func Convert(sql string) (dsl string, table string, err error) {
-	stmt, err := sqlparser.Parse(sql)
+	return ConvertWithOptions(sql, Options{})
+}
 
+// ConvertWithOptions is Convert with the translation controlled by opts
+func ConvertWithOptions(sql string, opts Options) (dsl string, table string, err error) {
+	stmt, err := ConvertStatement(sql, opts)
 	if err != nil {
 		return "", "", err
 	}
 
+	return stmt.DSL, stmt.Table, nil
+}
+
+// ConvertStatement is ConvertWithOptions which also returns the columns of select
+func ConvertStatement(sql string, opts Options) (*Statement, error) {
+	stmt, err := sqlparser.Parse(keepLikeEscapes(sql))
+
+	if err != nil {
+		return nil, newSyntaxError(err)
+	}
+
+	err = bindParams(stmt, sql, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	//sql valid, start to handle
+	var result = &Statement{}
 	switch stmt.(type) {
 	case *sqlparser.Select:
-		dsl, table, err = handleSelect(stmt.(*sqlparser.Select))
+		result, err = handleSelect(stmt.(*sqlparser.Select), opts.withDefaults())
 	case *sqlparser.Update:
-		return handleUpdate(stmt.(*sqlparser.Update))
+		result, err = handleUpdate(stmt.(*sqlparser.Update), opts.withDefaults())
 	case *sqlparser.Insert:
-		return handleInsert(stmt.(*sqlparser.Insert))
+		result, err = handleInsert(stmt.(*sqlparser.Insert), opts.withDefaults())
 	case *sqlparser.Delete:
-		return handleDelete(stmt.(*sqlparser.Delete))
+		result, err = handleDelete(stmt.(*sqlparser.Delete), opts.withDefaults())
+	default:
+		err = unsupportedError("statement", sqlparser.String(stmt), "only select, update, insert and delete are supported")
 	}
 
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return dsl, table, nil
+	return result, nil
+}
+
+// SplitStatements splits the sql separated by ; into statements, the ; in quotes and comments are kept,
+// the empty statements are skipped
+func SplitStatements(sql string) ([]string, error) {
+	var statements []string
+	for rest := sql; strings.TrimSpace(rest) != ""; {
+		var statement string
+		var err error
+		statement, rest, err = sqlparser.SplitStatement(rest)
+		if err != nil {
+			return nil, newSyntaxError(err)
+		}
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements, nil
 }
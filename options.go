@@ -0,0 +1,203 @@
+package elasticsql
+
+import (
+	"encoding/json"
+)
+
+// EqualityQuery decides which query = and != are translated to
+type EqualityQuery int
+
+const (
+	// MatchPhraseEquality translates a = 1 to a match_phrase query, this is the default
+	MatchPhraseEquality EqualityQuery = iota
+	// TermEquality translates a = 1 to a term query
+	TermEquality
+)
+
+// default values used when the field of Options is not set
+const (
+	defaultSize         = 1
+	defaultTermsSize    = 200
+	defaultDateFormat   = "yyyy-MM-dd HH:mm:ss"
+	defaultDateInterval = "1h"
+	defaultRegexpFlags  = "NONE"
+)
+
+// Options controls how sql is translated to dsl
+// the zero value keeps the behaviour of Convert
+type Options struct {
+	// Equality is the query used for = and !=
+	Equality EqualityQuery
+
+	// TargetVersion is the version of elasticsearch or opensearch the dsl is built for,
+	// DefaultVersion if not set, which keeps the dsl of the previous releases
+	TargetVersion Version
+
+	// FieldTypes maps field names to their elasticsearch mapping types, eg. text, keyword, long
+	// it overrides Equality for the fields in the map, analyzed text fields use match_phrase
+	// and all the other types use term, FieldTypesFromMapping builds it from a _mapping response
+	FieldTypes map[string]string
+
+	// RegexpFlags are the flags of the regexp query built from regexp and rlike, "NONE" if not set
+	// NONE keeps the lucene only operators like @, &, ~, <> as literal characters the same as mysql
+	RegexpFlags string
+
+	// DefaultSize is the size of the query when there is no limit, 1 if not set
+	// use limit 0 in sql to get no hits at all
+	DefaultSize int
+
+	// TermsSize is the size of the outermost terms aggregation built from group by, 200 if not set
+	TermsSize int
+
+	// DateFormat is the default format of date_histogram and date_range, "yyyy-MM-dd HH:mm:ss" if not set
+	DateFormat string
+
+	// DateInterval is the default interval of date_histogram, "1h" if not set
+	DateInterval string
+
+	// CompositeAggregation translates group by to a single composite aggregation instead of
+	// nested bucket aggregations, so all the groups can be paged through with NextCompositePage
+	// TermsSize or limit is the number of groups of a page
+	CompositeAggregation bool
+
+	// DocValueFields requests the selected columns as docvalue_fields besides _source
+	DocValueFields bool
+
+	// StoredFields requests the selected columns as stored_fields besides _source
+	StoredFields bool
+
+	// DocType is the _type of the documents inserted into elasticsearch before 7.x, the type of the mapping of the index,
+	// _doc if not set for 6.x, insert needs it for 2.x and 5.x
+	DocType string
+
+	// AllowUnconditionalDelete allows delete without where, which removes all the documents of the index
+	AllowUnconditionalDelete bool
+
+	// Params are the values of the ? placeholders in order
+	// the values keep their json types, eg. int64 is a number and string is a string
+	Params []interface{}
+
+	// NamedParams are the values of the :name placeholders, and the slices of the ::name list placeholders,
+	// eg. where id in ::ids and name = :name, the keys have no colons
+	NamedParams map[string]interface{}
+}
+
+// withDefaults fills the unset fields with the default values
+func (opts Options) withDefaults() *Options {
+	if opts.DefaultSize == 0 {
+		opts.DefaultSize = defaultSize
+	}
+	if opts.TermsSize == 0 {
+		opts.TermsSize = defaultTermsSize
+	}
+	if opts.DateFormat == "" {
+		opts.DateFormat = defaultDateFormat
+	}
+	if opts.DateInterval == "" {
+		opts.DateInterval = defaultDateInterval
+	}
+	if opts.RegexpFlags == "" {
+		opts.RegexpFlags = defaultRegexpFlags
+	}
+	return &opts
+}
+
+// the mapping types which are analyzed, so their values are split into terms
+var analyzedFieldTypes = map[string]bool{
+	"text":               true,
+	"string":             true, // elasticsearch 2.x
+	"match_only_text":    true,
+	"search_as_you_type": true,
+}
+
+// equalityOf returns the query used for = and != on field
+func (opts *Options) equalityOf(field string) EqualityQuery {
+	typ, ok := opts.FieldTypes[field]
+	if !ok {
+		return opts.Equality
+	}
+	if analyzedFieldTypes[typ] {
+		return MatchPhraseEquality
+	}
+	return TermEquality
+}
+
+// FieldTypesFromMapping builds Options.FieldTypes from the response of GET index/_mapping
+// object fields are flattened to dotted names, multi-fields become name.keyword like names
+func FieldTypesFromMapping(mapping []byte) (map[string]string, error) {
+	var indices map[string]struct {
+		Mappings map[string]json.RawMessage `json:"mappings"`
+	}
+	err := json.Unmarshal(mapping, &indices)
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldTypes = make(map[string]string)
+	for _, index := range indices {
+		// typeless mappings since 7.x have properties directly under mappings,
+		// before that every document type has its own properties
+		if properties, ok := index.Mappings["properties"]; ok {
+			err = collectFieldTypes(properties, "", fieldTypes)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, docType := range index.Mappings {
+			var typeMapping struct {
+				Properties json.RawMessage `json:"properties"`
+			}
+			err = json.Unmarshal(docType, &typeMapping)
+			if err != nil {
+				return nil, err
+			}
+			if typeMapping.Properties == nil {
+				continue
+			}
+			err = collectFieldTypes(typeMapping.Properties, "", fieldTypes)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(fieldTypes) == 0 {
+		return nil, valueError("mapping", "", "no field found in mapping")
+	}
+	return fieldTypes, nil
+}
+
+func collectFieldTypes(properties json.RawMessage, prefix string, fieldTypes map[string]string) error {
+	var fields map[string]struct {
+		Type       string          `json:"type"`
+		Index      interface{}     `json:"index"`
+		Properties json.RawMessage `json:"properties"`
+		Fields     json.RawMessage `json:"fields"`
+	}
+	err := json.Unmarshal(properties, &fields)
+	if err != nil {
+		return err
+	}
+
+	for name, field := range fields {
+		name = prefix + name
+		if field.Type != "" {
+			fieldTypes[name] = field.Type
+		}
+		// the string of 2.x is the same as keyword if it is not analyzed
+		if field.Type == "string" && field.Index == "not_analyzed" {
+			fieldTypes[name] = "keyword"
+		}
+		for _, children := range []json.RawMessage{field.Properties, field.Fields} {
+			if children == nil {
+				continue
+			}
+			err = collectFieldTypes(children, name+".", fieldTypes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package elasticsql
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// paramBinder replaces the placeholders of the statement with the values of the params
+// the values are put into the syntax tree as literals, they are never parsed as sql
+type paramBinder struct {
+	params      []interface{}
+	namedParams map[string]interface{}
+	// positional maps the names of the ? placeholders to their positions in params
+	positional map[string]int
+}
+
+func bindParams(stmt sqlparser.Statement, sql string, opts Options) error {
+	positional, err := scanPlaceholders(sql)
+	if err != nil {
+		return err
+	}
+	b := &paramBinder{params: opts.Params, namedParams: opts.NamedParams, positional: positional}
+	if err = b.bindStatement(stmt); err != nil {
+		return err
+	}
+	return b.checkUnbound(stmt)
+}
+
+func (b *paramBinder) bindStatement(stmt sqlparser.Statement) error {
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		for _, v := range s.SelectExprs {
+			if expr, ok := v.(*sqlparser.AliasedExpr); ok {
+				if err := b.bind(&expr.Expr); err != nil {
+					return err
+				}
+			}
+		}
+		for i := range s.GroupBy {
+			// a param can not be the column of group by
+			if isPlaceholder(s.GroupBy[i]) {
+				continue
+			}
+			if err := b.bind(&s.GroupBy[i]); err != nil {
+				return err
+			}
+		}
+		if s.Having != nil {
+			if err := b.bind(&s.Having.Expr); err != nil {
+				return err
+			}
+		}
+		return b.bindCommon(s.Where, s.OrderBy, s.Limit)
+	case *sqlparser.Update:
+		for _, v := range s.Exprs {
+			if err := b.bind(&v.Expr); err != nil {
+				return err
+			}
+		}
+		return b.bindCommon(s.Where, s.OrderBy, s.Limit)
+	case *sqlparser.Delete:
+		return b.bindCommon(s.Where, s.OrderBy, s.Limit)
+	case *sqlparser.Insert:
+		if rows, ok := s.Rows.(sqlparser.Values); ok {
+			for _, row := range rows {
+				for i := range row {
+					if err := b.bind(&row[i]); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, v := range s.OnDup {
+			if err := b.bind(&v.Expr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bindCommon binds the clauses shared by select, update and delete
+func (b *paramBinder) bindCommon(where *sqlparser.Where, orderBy sqlparser.OrderBy, limit *sqlparser.Limit) error {
+	if where != nil {
+		if err := b.bind(&where.Expr); err != nil {
+			return err
+		}
+	}
+	for _, v := range orderBy {
+		// a param can not be the column of order by
+		if isPlaceholder(v.Expr) {
+			continue
+		}
+		if err := b.bind(&v.Expr); err != nil {
+			return err
+		}
+	}
+	if limit != nil {
+		if err := b.bind(&limit.Offset); err != nil {
+			return err
+		}
+		if err := b.bind(&limit.Rowcount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bind replaces the placeholders in the expression in place
+func (b *paramBinder) bind(expr *sqlparser.Expr) error {
+	var err error
+	switch e := (*expr).(type) {
+	case *sqlparser.SQLVal:
+		if e.Type == sqlparser.ValArg {
+			*expr, err = b.lookup(string(e.Val))
+		}
+	case sqlparser.ListArg:
+		*expr, err = b.lookup(string(e))
+	case sqlparser.ValTuple:
+		var values sqlparser.ValTuple
+		for i := range e {
+			if err = b.bind(&e[i]); err != nil {
+				return err
+			}
+			// in (?) with a list param is the same as in ::list
+			if tuple, ok := e[i].(sqlparser.ValTuple); ok {
+				values = append(values, tuple...)
+			} else {
+				values = append(values, e[i])
+			}
+		}
+		*expr = values
+	case *sqlparser.AndExpr:
+		err = b.bindAll(&e.Left, &e.Right)
+	case *sqlparser.OrExpr:
+		err = b.bindAll(&e.Left, &e.Right)
+	case *sqlparser.NotExpr:
+		err = b.bind(&e.Expr)
+	case *sqlparser.ParenExpr:
+		err = b.bind(&e.Expr)
+	case *sqlparser.ComparisonExpr:
+		err = b.bindAll(&e.Left, &e.Right, &e.Escape)
+	case *sqlparser.RangeCond:
+		err = b.bindAll(&e.Left, &e.From, &e.To)
+	case *sqlparser.IsExpr:
+		err = b.bind(&e.Expr)
+	case *sqlparser.BinaryExpr:
+		err = b.bindAll(&e.Left, &e.Right)
+	case *sqlparser.UnaryExpr:
+		err = b.bind(&e.Expr)
+	case *sqlparser.FuncExpr:
+		for _, v := range e.Exprs {
+			if aliasedExpr, ok := v.(*sqlparser.AliasedExpr); ok {
+				if err = b.bind(&aliasedExpr.Expr); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return err
+}
+
+func isPlaceholder(node sqlparser.SQLNode) bool {
+	switch n := node.(type) {
+	case *sqlparser.SQLVal:
+		return n.Type == sqlparser.ValArg
+	case sqlparser.ListArg:
+		return true
+	}
+	return false
+}
+
+// checkUnbound returns an error naming the node of the placeholders which are not bound,
+// eg. the placeholders in order by, subqueries and case
+func (b *paramBinder) checkUnbound(stmt sqlparser.Statement) error {
+	var err error
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		var found bool
+		// only the children of the node are checked, so the node is the one the placeholder is in
+		node.WalkSubtree(func(child sqlparser.SQLNode) (bool, error) {
+			found = found || isPlaceholder(child)
+			return false, nil
+		})
+		if found {
+			err = unsupportedError("param", b.restorePlaceholders(sqlparser.String(node)), "the placeholder can not be used here")
+		}
+		return err == nil, nil
+	}, stmt)
+	return err
+}
+
+var positionalNamePattern = regexp.MustCompile(`:v\d+`)
+
+// restorePlaceholders turns the names of the ? placeholders in the sql back to ?
+func (b *paramBinder) restorePlaceholders(sql string) string {
+	return positionalNamePattern.ReplaceAllStringFunc(sql, func(name string) string {
+		if _, ok := b.positional[name]; ok {
+			return "?"
+		}
+		return name
+	})
+}
+
+func (b *paramBinder) bindAll(exprs ...*sqlparser.Expr) error {
+	for _, expr := range exprs {
+		if err := b.bind(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanPlaceholders returns the names the parser gives to the ? placeholders, :v1, :v2 ...
+// and their positions, the :name written in the sql can not have the same names
+// the sql is scanned by the rules of the tokenizer of sqlparser, so the ? and : in the strings,
+// the quoted identifiers and the comments are skipped
+func scanPlaceholders(sql string) (map[string]int, error) {
+	var positional = make(map[string]int)
+	var named []string
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case sql[i] == '\'' || sql[i] == '"' || sql[i] == '`':
+			i = skipQuoted(sql, i)
+		case sql[i] == '#' || strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "//"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += 2 + end + 1
+			} else {
+				i = len(sql)
+			}
+		case sql[i] == '?':
+			position := len(positional) + 1
+			positional[":v"+strconv.Itoa(position)] = position
+		case sql[i] == ':':
+			end := i + 1
+			if end < len(sql) && sql[end] == ':' {
+				end++
+			}
+			for end < len(sql) && isBindVarChar(sql[end]) {
+				end++
+			}
+			named = append(named, sql[i:end])
+			i = end - 1
+		}
+	}
+
+	for _, placeholder := range named {
+		if _, ok := positional[placeholder]; ok {
+			return nil, valueError("param", placeholder, "the named placeholder has the same name as a ? placeholder")
+		}
+	}
+	return positional, nil
+}
+
+// skipQuoted returns the index of the closing quote of the string or the identifier starting at i
+// the quote is escaped by doubling it, or by \ in the strings
+func skipQuoted(sql string, i int) int {
+	quote := sql[i]
+	for i++; i < len(sql); i++ {
+		switch {
+		case sql[i] == '\\' && quote != '`':
+			i++
+		case sql[i] == quote && i+1 < len(sql) && sql[i+1] == quote:
+			i++
+		case sql[i] == quote:
+			return i
+		}
+	}
+	return i
+}
+
+// the chars of the names of the placeholders, eg. :name, :a.b
+func isBindVarChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '@' || c == '.'
+}
+
+// lookup returns the literal of the placeholder
+// ? is looked up in the params by position, :name and ::name are looked up in the named params
+func (b *paramBinder) lookup(placeholder string) (sqlparser.Expr, error) {
+	if index, ok := b.positional[placeholder]; ok {
+		if index > len(b.params) {
+			return nil, valueError("param", "?", "no value for the placeholder")
+		}
+		return buildParamExpr("?", b.params[index-1])
+	}
+	if val, ok := b.namedParams[strings.TrimLeft(placeholder, ":")]; ok {
+		return buildParamExpr(placeholder, val)
+	}
+	return nil, valueError("param", placeholder, "no value for the placeholder")
+}
+
+// buildParamExpr converts the go value to the literal of the same json type
+func buildParamExpr(placeholder string, val interface{}) (sqlparser.Expr, error) {
+	switch v := val.(type) {
+	case nil:
+		return &sqlparser.NullVal{}, nil
+	case bool:
+		return sqlparser.BoolVal(v), nil
+	case string:
+		return sqlparser.NewStrVal([]byte(v)), nil
+	case []byte:
+		return sqlparser.NewStrVal(v), nil
+	case time.Time:
+		return sqlparser.NewStrVal([]byte(v.Format(time.RFC3339Nano))), nil
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sqlparser.NewIntVal([]byte(strconv.FormatInt(rv.Int(), 10))), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sqlparser.NewIntVal([]byte(strconv.FormatUint(rv.Uint(), 10))), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, valueError("param", placeholder, "the number must be finite")
+		}
+		return sqlparser.NewFloatVal([]byte(strconv.FormatFloat(f, 'g', -1, 64))), nil
+	case reflect.String:
+		return sqlparser.NewStrVal([]byte(rv.String())), nil
+	case reflect.Bool:
+		return sqlparser.BoolVal(rv.Bool()), nil
+	case reflect.Slice, reflect.Array:
+		var tuple = make(sqlparser.ValTuple, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := buildParamExpr(placeholder, rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := elem.(sqlparser.ValTuple); ok {
+				return nil, valueError("param", placeholder, "the list param can not be nested")
+			}
+			tuple[i] = elem
+		}
+		return tuple, nil
+	}
+	return nil, valueError("param", placeholder, "unsupported param type "+rv.Type().String())
+}
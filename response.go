@@ -0,0 +1,339 @@
+package elasticsql
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ResultSet is the response of a select in the shape of sql
+type ResultSet struct {
+	// Columns are the names of the columns, * is expanded to the fields of the hits
+	Columns []string
+	// Rows are the values in the order of Columns, the numbers are int64 or float64,
+	// the objects and arrays are map[string]interface{} and []interface{}
+	Rows [][]interface{}
+}
+
+// ResponseError is the error returned by elasticsearch instead of the result
+type ResponseError struct {
+	// Status is the http status in the response
+	Status int
+	// Type is the type of the root cause, eg. index_not_found_exception
+	Type string
+	// Reason is the message of elasticsearch
+	Reason string
+}
+
+func (e *ResponseError) Error() string {
+	return "elasticsql: " + e.Type + ": " + e.Reason
+}
+
+type searchHit struct {
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+type searchResponse struct {
+	Hits struct {
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]interface{} `json:"aggregations"`
+	Error        json.RawMessage        `json:"error"`
+	Status       int                    `json:"status"`
+}
+
+// ConvertResponse translates the response of the _search request of stmt to rows
+// the hits are the rows of a query, there is one row for each innermost bucket of group by,
+// and only one row for the aggregate functions without group by
+func ConvertResponse(stmt *Statement, response []byte) (*ResultSet, error) {
+	if stmt.Endpoint != SearchEndpoint {
+		return nil, unsupportedError("endpoint", stmt.Endpoint, "only the response of select can be converted to rows")
+	}
+
+	var resp searchResponse
+	decoder := json.NewDecoder(bytes.NewReader(response))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, buildResponseError(resp.Error, resp.Status)
+	}
+
+	if isAggStatement(stmt) {
+		return convertAggResponse(stmt, resp.Aggregations), nil
+	}
+	return convertHitsResponse(stmt, resp.Hits.Hits), nil
+}
+
+// CheckResponse returns the ResponseError in the response of any request, nil if there is no error
+func CheckResponse(response []byte) error {
+	var resp struct {
+		Error  json.RawMessage `json:"error"`
+		Status int             `json:"status"`
+	}
+	if err := json.Unmarshal(response, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return buildResponseError(resp.Error, resp.Status)
+	}
+	return nil
+}
+
+// the error is an object since 5.x, and a string in 2.x
+func buildResponseError(raw json.RawMessage, status int) error {
+	var body struct {
+		Type      string `json:"type"`
+		Reason    string `json:"reason"`
+		RootCause []struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"root_cause"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		var reason string
+		if json.Unmarshal(raw, &reason) != nil {
+			reason = string(raw)
+		}
+		return &ResponseError{Status: status, Reason: reason}
+	}
+	if len(body.RootCause) > 0 {
+		body.Type, body.Reason = body.RootCause[0].Type, body.RootCause[0].Reason
+	}
+	return &ResponseError{Status: status, Type: body.Type, Reason: body.Reason}
+}
+
+func isAggStatement(stmt *Statement) bool {
+	for _, column := range stmt.Columns {
+		if column.Kind == BucketColumn || column.Kind == MetricColumn {
+			return true
+		}
+	}
+	return len(stmt.Buckets) > 0
+}
+
+func convertHitsResponse(stmt *Statement, hits []searchHit) *ResultSet {
+	var result = &ResultSet{Rows: [][]interface{}{}}
+	var columns []Column
+
+	// * and x.* are expanded to the fields of all the hits
+	for _, column := range stmt.Columns {
+		if column.Kind != FieldColumn || !strings.HasSuffix(column.Key, "*") {
+			columns = append(columns, column)
+			continue
+		}
+		prefix := strings.TrimSuffix(column.Key, "*")
+		fieldSet := make(map[string]bool)
+		for _, hit := range hits {
+			for field := range flattenObject(hit.Source, "") {
+				if strings.HasPrefix(field, prefix) {
+					fieldSet[field] = true
+				}
+			}
+		}
+		var fields []string
+		for field := range fieldSet {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			columns = append(columns, Column{Name: field, Key: field, Kind: FieldColumn})
+		}
+	}
+
+	for _, column := range columns {
+		result.Columns = append(result.Columns, column.Name)
+	}
+	for _, hit := range hits {
+		var row = make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i] = hitValue(hit, column)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+// hitValue returns the value of the column in the hit, the fields of the hit
+// are used when the field is not in the _source, eg. docvalue_fields
+func hitValue(hit searchHit, column Column) interface{} {
+	if column.Kind == FieldColumn {
+		if column.Key == idColumn {
+			return hit.ID
+		}
+		if val, ok := lookupPath(hit.Source, column.Key); ok {
+			return normalizeJSONValue(val)
+		}
+	}
+
+	// the values of fields are always arrays
+	if values, ok := hit.Fields[column.Key].([]interface{}); ok {
+		if len(values) == 1 {
+			return normalizeJSONValue(values[0])
+		}
+		return normalizeJSONValue(values)
+	}
+	return nil
+}
+
+// lookupPath returns the value of the dotted path a.b, which is either
+// the field b of the object a or the field named a.b
+func lookupPath(object map[string]interface{}, path string) (interface{}, bool) {
+	if val, ok := object[path]; ok {
+		return val, true
+	}
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	child, ok := object[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(child, parts[1])
+}
+
+// flattenObject flattens the inner objects to dotted names, the arrays are kept as values
+func flattenObject(object map[string]interface{}, prefix string) map[string]interface{} {
+	var flattened = make(map[string]interface{})
+	for k, v := range object {
+		if child, ok := v.(map[string]interface{}); ok {
+			for childKey, childVal := range flattenObject(child, prefix+k+".") {
+				flattened[childKey] = childVal
+			}
+			continue
+		}
+		flattened[prefix+k] = v
+	}
+	return flattened
+}
+
+func convertAggResponse(stmt *Statement, aggs map[string]interface{}) *ResultSet {
+	var result = &ResultSet{Rows: [][]interface{}{}}
+	for _, column := range stmt.Columns {
+		result.Columns = append(result.Columns, column.Name)
+	}
+
+	var walk func(bucket map[string]interface{}, depth int, keys map[string]interface{})
+	walk = func(bucket map[string]interface{}, depth int, keys map[string]interface{}) {
+		if depth == len(stmt.Buckets) {
+			result.Rows = append(result.Rows, buildBucketRow(stmt.Columns, bucket, keys))
+			return
+		}
+
+		name := stmt.Buckets[depth]
+		agg, _ := bucket[name].(map[string]interface{})
+		for _, child := range bucketsOf(agg) {
+			var childKeys = make(map[string]interface{}, len(keys)+1)
+			for k, v := range keys {
+				childKeys[k] = v
+			}
+			// the key of composite buckets is an object of the values of the sources
+			if sources, ok := child["key"].(map[string]interface{}); ok {
+				for k, v := range sources {
+					childKeys[k] = normalizeJSONValue(v)
+				}
+			} else {
+				childKeys[name] = bucketKey(child)
+			}
+			walk(child, depth+1, childKeys)
+		}
+	}
+	walk(aggs, 0, map[string]interface{}{})
+
+	return result
+}
+
+// bucketsOf returns the buckets of the aggregation, the keyed buckets are sorted by key
+func bucketsOf(agg map[string]interface{}) []map[string]interface{} {
+	var buckets []map[string]interface{}
+	switch items := agg["buckets"].(type) {
+	case []interface{}:
+		for _, item := range items {
+			if bucket, ok := item.(map[string]interface{}); ok {
+				buckets = append(buckets, bucket)
+			}
+		}
+	case map[string]interface{}:
+		var keys []string
+		for k := range items {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if bucket, ok := items[k].(map[string]interface{}); ok {
+				if _, ok := bucket["key"]; !ok {
+					bucket["key"] = k
+				}
+				buckets = append(buckets, bucket)
+			}
+		}
+	}
+	return buckets
+}
+
+// bucketKey returns the formatted key of the bucket if there is one, eg. the date of date_histogram
+func bucketKey(bucket map[string]interface{}) interface{} {
+	if key, ok := bucket["key_as_string"]; ok {
+		return key
+	}
+	return normalizeJSONValue(bucket["key"])
+}
+
+func buildBucketRow(columns []Column, bucket map[string]interface{}, keys map[string]interface{}) []interface{} {
+	var row = make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column.Kind {
+		case BucketColumn:
+			row[i] = keys[column.Key]
+		case MetricColumn:
+			row[i] = metricValue(bucket[column.Key])
+		}
+	}
+	return row
+}
+
+// metricValue returns the value of the single value metrics,
+// the values of percentiles and the object of the other multi value metrics, eg. stats
+func metricValue(metric interface{}) interface{} {
+	object, ok := metric.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if val, ok := object["value"]; ok {
+		return normalizeJSONValue(val)
+	}
+	if values, ok := object["values"]; ok {
+		return normalizeJSONValue(values)
+	}
+	return normalizeJSONValue(object)
+}
+
+// normalizeJSONValue converts the json numbers to int64 or float64
+func normalizeJSONValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		var object = make(map[string]interface{}, len(v))
+		for k, child := range v {
+			object[k] = normalizeJSONValue(child)
+		}
+		return object
+	case []interface{}:
+		var array = make([]interface{}, len(v))
+		for i, child := range v {
+			array[i] = normalizeJSONValue(child)
+		}
+		return array
+	}
+	return val
+}
@@ -0,0 +1,102 @@
+package elasticsql
+
+import (
+	"reflect"
+	"testing"
+)
+
+var responseCaseList = []struct {
+	sql      string
+	opts     Options
+	response string
+	columns  []string
+	rows     [][]interface{}
+}{
+	{
+		"select id, user.name as name, price * 2 as double_price from a", Options{},
+		`{"hits":{"hits":[{"_id":"1","_source":{"id":1,"user":{"name":"x"}},"fields":{"double_price":[3.5]}},{"_id":"2","_source":{"id":2,"user.name":"y"},"fields":{"double_price":[4]}}]}}`,
+		[]string{"id", "name", "double_price"},
+		[][]interface{}{{int64(1), "x", 3.5}, {int64(2), "y", int64(4)}},
+	},
+	{
+		"select *, _id from a", Options{},
+		`{"hits":{"hits":[{"_id":"1","_source":{"b":true,"a":{"x":1}}},{"_id":"2","_source":{"c":[1,2]}}]}}`,
+		[]string{"a.x", "b", "c", "_id"},
+		[][]interface{}{{int64(1), true, nil, "1"}, {nil, nil, []interface{}{int64(1), int64(2)}, "2"}},
+	},
+	{
+		"select id from a", Options{DocValueFields: true},
+		`{"hits":{"hits":[{"_id":"1","_source":{},"fields":{"id":[1]}}]}}`,
+		[]string{"id"},
+		[][]interface{}{{int64(1)}},
+	},
+	{
+		"select id from a", Options{},
+		`{"hits":{"hits":[]}}`,
+		[]string{"id"},
+		[][]interface{}{},
+	},
+	{
+		"select count(*), avg(price) as p, stats(price) from a", Options{},
+		`{"hits":{"hits":[]},"aggregations":{"COUNT(*)":{"value":10},"p":{"value":1.5},"STATS(price)":{"count":2,"min":1,"max":2,"avg":1.5,"sum":3}}}`,
+		[]string{"count(*)", "p", "stats(price)"},
+		[][]interface{}{{int64(10), 1.5, map[string]interface{}{"count": int64(2), "min": int64(1), "max": int64(2), "avg": 1.5, "sum": int64(3)}}},
+	},
+	{
+		"select region, city as c, count(*) from a group by region, c", Options{},
+		`{"aggregations":{"region":{"buckets":[{"key":"bj","doc_count":3,"c":{"buckets":[{"key":"cy","doc_count":2,"COUNT(*)":{"value":2}},{"key":"hd","doc_count":1,"COUNT(*)":{"value":1}}]}},{"key":"sh","doc_count":1,"c":{"buckets":[{"key":"pd","doc_count":1,"COUNT(*)":{"value":1}}]}},{"key":"gz","doc_count":0,"c":{"buckets":[]}}]}}}`,
+		[]string{"region", "c", "count(*)"},
+		[][]interface{}{{"bj", "cy", int64(2)}, {"bj", "hd", int64(1)}, {"sh", "pd", int64(1)}},
+	},
+	{
+		"select date_histogram(field='ctime', value='1d') as d, sum(n) from a group by d", Options{},
+		`{"aggregations":{"d":{"buckets":[{"key":1577836800000,"key_as_string":"2020-01-01","doc_count":1,"SUM(n)":{"value":3}}]}}}`,
+		[]string{"d", "sum(n)"},
+		[][]interface{}{{"2020-01-01", int64(3)}},
+	},
+	{
+		"select region, count(*) from a group by region, city", Options{CompositeAggregation: true},
+		`{"aggregations":{"composite":{"after_key":{"region":"sh","city":"pd"},"buckets":[{"key":{"region":"bj","city":"cy"},"doc_count":2,"COUNT(*)":{"value":2}},{"key":{"region":"sh","city":"pd"},"doc_count":1,"COUNT(*)":{"value":1}}]}}}`,
+		[]string{"region", "count(*)"},
+		[][]interface{}{{"bj", int64(2)}, {"sh", int64(1)}},
+	},
+}
+
+func TestConvertResponse(t *testing.T) {
+	for _, c := range responseCaseList {
+		stmt, err := ConvertStatement(c.sql, c.opts)
+		if err != nil {
+			t.Error("convert failed", c.sql, err)
+			continue
+		}
+
+		result, err := ConvertResponse(stmt, []byte(c.response))
+		if err != nil {
+			t.Error("convert response failed", c.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Columns, c.columns) {
+			t.Error("the columns are not equal to expected", c.sql, result.Columns)
+		}
+		if !reflect.DeepEqual(result.Rows, c.rows) {
+			t.Error("the rows are not equal to expected", c.sql, result.Rows)
+		}
+	}
+
+	stmt, _ := ConvertStatement("select * from a", Options{})
+	_, err := ConvertResponse(stmt, []byte(`{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index [a]"}],"type":"index_not_found_exception","reason":"no such index [a]"},"status":404}`))
+	responseErr, ok := err.(*ResponseError)
+	if !ok || responseErr.Status != 404 || responseErr.Type != "index_not_found_exception" {
+		t.Error("the response error is not equal to expected", err)
+	}
+
+	_, err = ConvertResponse(stmt, []byte(`{"error":"IndexMissingException[[a] missing]","status":404}`))
+	if responseErr, ok := err.(*ResponseError); !ok || responseErr.Reason != "IndexMissingException[[a] missing]" {
+		t.Error("the response error of 2.x is not equal to expected", err)
+	}
+
+	stmt, _ = ConvertStatement("delete from a where id = 1", Options{})
+	if _, err = ConvertResponse(stmt, []byte(`{"deleted":1}`)); ErrorCodeOf(err) != ErrCodeUnsupported {
+		t.Error("can not be true, only the response of select can be converted")
+	}
+}
@@ -1,8 +1,6 @@
 package elasticsql
 
 import (
-	"encoding/json"
-	"errors"
 	"strings"
 
 	"github.com/xwb1989/sqlparser"
@@ -11,81 +9,94 @@ import (
 // msi stands for map[string]interface{}
 type msi map[string]interface{}
 
-func handleFuncInSelectAgg(funcExprArr []*sqlparser.FuncExpr) msi {
+func handleFuncInSelectAgg(funcExprArr []*sqlparser.FuncExpr, aliases *selectAliases) aggregations {
 
-	var innerAggMap = make(msi)
+	var innerAggs aggregations
 	for _, v := range funcExprArr {
 		//func expressions will use the same parent bucket
+		innerAggs = innerAggs.add(buildFuncAgg(v, aliases))
+	}
 
-		aggName := strings.ToUpper(v.Name.String()) + `(` + sqlparser.String(v.Exprs) + `)`
-		switch v.Name.Lowered() {
-		case "count":
-			//count need to distinguish * and normal field name
-			if sqlparser.String(v.Exprs) == "*" {
-				innerAggMap[aggName] = msi{
-					"value_count": msi{
-						"field": "_index",
-					},
-				}
-			} else {
-				// support count(distinct field)
-				if v.Distinct {
-					innerAggMap[aggName] = msi{
-						"cardinality": msi{
-							"field": sqlparser.String(v.Exprs),
-						},
-					}
-				} else {
-					innerAggMap[aggName] = msi{
-						"value_count": msi{
-							"field": sqlparser.String(v.Exprs),
-						},
-					}
-				}
-			}
-		default:
-			// support min/avg/max/stats
-			// extended_stats/percentiles
-			innerAggMap[aggName] = msi{
-				v.Name.String(): msi{
-					"field": sqlparser.String(v.Exprs),
-				},
+	return innerAggs
+
+}
+
+// the name of the aggregation built from an aggregate function, eg. COUNT(*)
+func buildFuncAggName(v *sqlparser.FuncExpr) string {
+	return strings.ToUpper(v.Name.String()) + `(` + sqlparser.String(v.Exprs) + `)`
+}
+
+// buildMetricName returns the name of the metric aggregation of the function, the alias in select is used if there is one
+// . separates the metric of a multi-value aggregation in buckets_path and the order of terms,
+// so it is replaced in the name, eg. AVG(user_age) for avg(user.age)
+func buildMetricName(v *sqlparser.FuncExpr, aliases *selectAliases) string {
+	return strings.Replace(aliases.nameOf(v, buildFuncAggName(v)), ".", "_", -1)
+}
+
+func buildFuncAgg(v *sqlparser.FuncExpr, aliases *selectAliases) *aggregation {
+	aggName := buildMetricName(v, aliases)
+	switch v.Name.Lowered() {
+	case "count":
+		//count need to distinguish * and normal field name
+		if sqlparser.String(v.Exprs) == "*" {
+			return &aggregation{
+				name:   aggName,
+				kind:   "value_count",
+				params: msi{"field": "_index"},
 			}
 		}
 
+		// support count(distinct field)
+		if v.Distinct {
+			return &aggregation{
+				name:   aggName,
+				kind:   "cardinality",
+				params: msi{"field": sqlparser.String(v.Exprs)},
+			}
+		}
+		return &aggregation{
+			name:   aggName,
+			kind:   "value_count",
+			params: msi{"field": sqlparser.String(v.Exprs)},
+		}
+	default:
+		// support min/avg/max/stats
+		// extended_stats/percentiles
+		return &aggregation{
+			name:   aggName,
+			kind:   v.Name.Lowered(),
+			params: msi{"field": sqlparser.String(v.Exprs)},
+		}
 	}
-
-	return innerAggMap
-
 }
 
-func handleGroupByColName(colName *sqlparser.ColName, index int, child msi) msi {
-	innerMap := make(msi)
+func handleGroupByColName(colName *sqlparser.ColName, index int, child aggregations, opts *Options) *aggregation {
+	agg := &aggregation{
+		name:     colName.Name.String(),
+		kind:     "terms",
+		children: child,
+	}
 	if index == 0 {
-		innerMap["terms"] = msi{
+		agg.params = msi{
 			"field": colName.Name.String(),
-			"size":  200, // this size may need to change ?
+			"size":  opts.TermsSize,
 		}
 	} else {
-		innerMap["terms"] = msi{
+		agg.params = msi{
 			"field": colName.Name.String(),
-			"size":  0,
+			"size":  opts.innerTermsSize(),
 		}
 	}
 
-	if len(child) > 0 {
-		innerMap["aggregations"] = child
-	}
-	return msi{colName.Name.String(): innerMap}
+	return agg
 }
 
-func handleGroupByFuncExprDateHisto(funcExpr *sqlparser.FuncExpr) (msi, error) {
-	innerMap := make(msi)
+func handleGroupByFuncExprDateHisto(funcExpr *sqlparser.FuncExpr, opts *Options) (*aggregation, error) {
 	var (
 		// default
 		field    = ""
-		interval = "1h"
-		format   = "yyyy-MM-dd HH:mm:ss"
+		interval = opts.DateInterval
+		format   = opts.DateFormat
 	)
 
 	//get field/interval and format
@@ -97,14 +108,13 @@ func handleGroupByFuncExprDateHisto(funcExpr *sqlparser.FuncExpr) (msi, error) {
 			comparisonExpr, ok := item.Expr.(*sqlparser.ComparisonExpr)
 
 			if !ok {
-				return nil, errors.New("elasticsql: unsupported expression in date_histogram")
+				return nil, unsupportedError("param", sqlparser.String(expr), "the params of date_histogram must be like field = x")
 			}
 			left, ok := comparisonExpr.Left.(*sqlparser.ColName)
 			if !ok {
-				return nil, errors.New("elaticsql: param error in date_histogram")
+				return nil, unsupportedError("param", sqlparser.String(expr), "the params of date_histogram must be like field = x")
 			}
-			rightStr := sqlparser.String(comparisonExpr.Right)
-			rightStr = strings.Replace(rightStr, `'`, ``, -1)
+			rightStr := buildAggParamStr(comparisonExpr.Right)
 			if left.Name.Lowered() == "field" {
 				field = rightStr
 			}
@@ -114,48 +124,68 @@ func handleGroupByFuncExprDateHisto(funcExpr *sqlparser.FuncExpr) (msi, error) {
 			if left.Name.Lowered() == "format" {
 				format = rightStr
 			}
-
-			innerMap["date_histogram"] = msi{
-				"field":    field,
-				"interval": interval,
-				"format":   format,
-			}
 		default:
-			return nil, errors.New("elasticsql: unsupported expression in date_histogram")
+			return nil, unsupportedError("param", sqlparser.String(expr), "the params of date_histogram must be like field = x")
 		}
 	}
-	return innerMap, nil
+	params := msi{
+		"field":  field,
+		"format": format,
+	}
+	params[opts.dateHistogramIntervalKey(interval)] = interval
+	return &aggregation{
+		kind:   "date_histogram",
+		params: params,
+	}, nil
 }
 
-func handleGroupByFuncExprRange(funcExpr *sqlparser.FuncExpr) (msi, error) {
-	if len(funcExpr.Exprs) < 3 {
-		return nil, errors.New("elasticsql: length of function range params must be > 3")
+// the params of group by functions may be either quoted or bare words
+func buildAggParamStr(expr sqlparser.Expr) string {
+	if val, ok := expr.(*sqlparser.SQLVal); ok {
+		return string(val.Val)
 	}
+	return strings.Replace(sqlparser.String(expr), "`", "", -1)
+}
 
-	var innerMap = make(msi)
-	rangeMapList := make([]msi, len(funcExpr.Exprs)-2)
+func handleGroupByFuncExprRange(funcExpr *sqlparser.FuncExpr) (*aggregation, error) {
+	if len(funcExpr.Exprs) < 3 {
+		return nil, unsupportedError("function", sqlparser.String(funcExpr), "range needs a field and at least 2 bounds")
+	}
 
-	for i := 1; i < len(funcExpr.Exprs)-1; i++ {
-		valFrom := sqlparser.String(funcExpr.Exprs[i])
-		valTo := sqlparser.String(funcExpr.Exprs[i+1])
-		rangeMapList[i-1] = msi{
-			"from": valFrom,
-			"to":   valTo,
+	rangeList := make([]interface{}, len(funcExpr.Exprs)-1)
+	for i := 1; i < len(funcExpr.Exprs); i++ {
+		aliasedExpr, ok := funcExpr.Exprs[i].(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, unsupportedError("param", sqlparser.String(funcExpr.Exprs[i]), "unsupported star expression in range")
 		}
+		val, err := buildValue(aliasedExpr.Expr)
+		if err != nil {
+			return nil, err
+		}
+		rangeList[i-1] = val
 	}
-	innerMap[funcExpr.Name.String()] = msi{
-		"field":  sqlparser.String(funcExpr.Exprs[0]),
-		"ranges": rangeMapList,
+
+	rangeMapList := make([]msi, len(rangeList)-1)
+	for i := 0; i < len(rangeList)-1; i++ {
+		rangeMapList[i] = msi{
+			"from": rangeList[i],
+			"to":   rangeList[i+1],
+		}
 	}
 
-	return innerMap, nil
+	return &aggregation{
+		kind: "range",
+		params: msi{
+			"field":  sqlparser.String(funcExpr.Exprs[0]),
+			"ranges": rangeMapList,
+		},
+	}, nil
 }
 
-func handleGroupByFuncExprDateRange(funcExpr *sqlparser.FuncExpr) (msi, error) {
-	var innerMap msi
+func handleGroupByFuncExprDateRange(funcExpr *sqlparser.FuncExpr, opts *Options) (*aggregation, error) {
 	var (
 		field        string
-		format       = "yyyy-MM-dd HH:mm:ss"
+		format       = opts.DateFormat
 		rangeList    = []string{}
 		rangeMapList = []msi{}
 	)
@@ -163,15 +193,13 @@ func handleGroupByFuncExprDateRange(funcExpr *sqlparser.FuncExpr) (msi, error) {
 	for _, expr := range funcExpr.Exprs {
 		nonStarExpr, ok := expr.(*sqlparser.AliasedExpr)
 		if !ok {
-			return nil, errors.New("elasticsql: unsupported star expression in function date_range")
+			return nil, unsupportedError("param", sqlparser.String(expr), "unsupported star expression in date_range")
 		}
 
 		switch item := nonStarExpr.Expr.(type) {
 		case *sqlparser.ComparisonExpr:
 			colName := sqlparser.String(item.Left)
-			equalVal := sqlparser.String(item.Right.(*sqlparser.SQLVal))
-			//fmt.Printf("%#v", sqlparser.String(item.Right))
-			equalVal = strings.Trim(equalVal, `'`)
+			equalVal := buildAggParamStr(item.Right)
 
 			switch colName {
 			case "field":
@@ -179,112 +207,293 @@ func handleGroupByFuncExprDateRange(funcExpr *sqlparser.FuncExpr) (msi, error) {
 			case "format":
 				format = equalVal
 			default:
-				return nil, errors.New("elasticsql: unsupported column name " + colName)
+				return nil, unsupportedError("param", sqlparser.String(item), "unsupported param of date_range")
 			}
 		case *sqlparser.SQLVal:
-			skippedString := strings.Trim(sqlparser.String(item), "`")
-			rangeList = append(rangeList, skippedString)
+			rangeList = append(rangeList, string(item.Val))
 		default:
-			return nil, errors.New("elasticsql: unsupported expression " + sqlparser.String(expr))
+			return nil, unsupportedError("param", sqlparser.String(expr), "unsupported param of date_range")
 		}
 	}
 
 	if len(field) == 0 {
-		return nil, errors.New("elasticsql: lack field of date_range")
+		return nil, unsupportedError("function", sqlparser.String(funcExpr), "date_range needs a field")
 	}
 
 	for i := 0; i < len(rangeList)-1; i++ {
 		tmpMap := msi{
-			"from": strings.Trim(rangeList[i], `'`),
-			"to":   strings.Trim(rangeList[i+1], `'`),
+			"from": rangeList[i],
+			"to":   rangeList[i+1],
 		}
 		rangeMapList = append(rangeMapList, tmpMap)
 	}
 
-	innerMap = msi{
-		"date_range": msi{
+	return &aggregation{
+		kind: "date_range",
+		params: msi{
 			"field":  field,
 			"ranges": rangeMapList,
 			"format": format,
 		},
-	}
-
-	return innerMap, nil
+	}, nil
 }
 
-func handleGroupByFuncExpr(funcExpr *sqlparser.FuncExpr, child msi) (msi, error) {
+func handleGroupByFuncExpr(funcExpr *sqlparser.FuncExpr, child aggregations, opts *Options) (*aggregation, error) {
 
-	var innerMap msi
+	var agg *aggregation
 	var err error
 
 	switch funcExpr.Name.Lowered() {
 	case "date_histogram":
-		innerMap, err = handleGroupByFuncExprDateHisto(funcExpr)
+		agg, err = handleGroupByFuncExprDateHisto(funcExpr, opts)
 	case "range":
-		innerMap, err = handleGroupByFuncExprRange(funcExpr)
+		agg, err = handleGroupByFuncExprRange(funcExpr)
 	case "date_range":
-		innerMap, err = handleGroupByFuncExprDateRange(funcExpr)
+		agg, err = handleGroupByFuncExprDateRange(funcExpr, opts)
 	default:
-		return nil, errors.New("elasticsql: unsupported group by functions" + sqlparser.String(funcExpr))
+		return nil, unsupportedError("function", sqlparser.String(funcExpr), "unsupported group by function")
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	if len(child) > 0 && innerMap != nil {
-		innerMap["aggregations"] = child
-	}
+	agg.children = child
+	agg.name = buildGroupByFuncAggName(funcExpr)
+	return agg, nil
+}
 
+// the name of the aggregation built from a group by function, eg. date_histogram(field=create_time,value=1h)
+func buildGroupByFuncAggName(funcExpr *sqlparser.FuncExpr) string {
 	stripedFuncExpr := sqlparser.String(funcExpr)
 	stripedFuncExpr = strings.Replace(stripedFuncExpr, " ", "", -1)
 	stripedFuncExpr = strings.Replace(stripedFuncExpr, "'", "", -1)
-	return msi{stripedFuncExpr: innerMap}, nil
+	return stripedFuncExpr
 }
 
-func handleGroupByAgg(groupBy sqlparser.GroupBy, innerMap msi) (msi, error) {
+// the name of the bucket aggregation built from a group by expression,
+// the alias of the expression in select if there is one
+func buildGroupByAggName(expr sqlparser.Expr, aliases *selectAliases) string {
+	switch item := expr.(type) {
+	case *sqlparser.ColName:
+		return aliases.nameOf(item, item.Name.String())
+	case *sqlparser.FuncExpr:
+		return aliases.nameOf(item, buildGroupByFuncAggName(item))
+	}
+	return aliases.nameOf(expr, sqlparser.String(expr))
+}
 
-	var aggMap = make(msi)
+// handleGroupByAgg nests the bucket aggregations in the order of group by,
+// levels are the bucket aggregations from the outermost to the innermost
+func handleGroupByAgg(groupBy sqlparser.GroupBy, innerAggs aggregations, aliases *selectAliases, opts *Options) (aggregations, []*aggregation, error) {
 
-	var child = innerMap
+	var child = innerAggs
+	var levels = make([]*aggregation, len(groupBy))
 
 	for i := len(groupBy) - 1; i >= 0; i-- {
 		v := groupBy[i]
 
 		switch item := v.(type) {
 		case *sqlparser.ColName:
-			currentMap := handleGroupByColName(item, i, child)
-			child = currentMap
+			currentAgg := handleGroupByColName(item, i, child, opts)
+			currentAgg.name = buildGroupByAggName(item, aliases)
+			child = aggregations{currentAgg}
+			levels[i] = currentAgg
 
 		case *sqlparser.FuncExpr:
-			currentMap, err := handleGroupByFuncExpr(item, child)
+			currentAgg, err := handleGroupByFuncExpr(item, child, opts)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			child = currentMap
+			currentAgg.name = buildGroupByAggName(item, aliases)
+			child = aggregations{currentAgg}
+			levels[i] = currentAgg
+		default:
+			return nil, nil, unsupportedError("expression", sqlparser.String(v), "unsupported group by expression")
 		}
 	}
-	aggMap = child
 
-	return aggMap, nil
+	return child, levels, nil
 }
 
-func buildAggs(sel *sqlparser.Select) (string, error) {
+// the bucket aggregations which can be sorted by key, doc count and metrics
+var orderableBucketKinds = map[string]bool{
+	"terms":          true,
+	"histogram":      true,
+	"date_histogram": true,
+}
 
-	funcExprArr, _, funcErr := extractFuncAndColFromSelect(sel.SelectExprs)
-	innerAggMap := handleFuncInSelectAgg(funcExprArr)
+// handleAggOrderBy sorts the buckets by the order by of sql
+//
+//	order by a group by column sorts the buckets of that column by key
+//	order by count(*) sorts the innermost buckets by doc count
+//	order by other aggregate functions sorts the innermost buckets by the metric
+func handleAggOrderBy(orderBy sqlparser.OrderBy, groupBy sqlparser.GroupBy, levels []*aggregation, aliases *selectAliases, opts *Options) error {
+	var innermost = levels[len(levels)-1]
+	var orders = make(map[*aggregation][]msi)
+
+	for _, orderByExpr := range orderBy {
+		var level *aggregation
+		var key string
+		var expr = aliases.resolve(orderByExpr.Expr)
+
+		for i, groupByExpr := range groupBy {
+			if sqlparser.String(groupByExpr) == sqlparser.String(expr) {
+				level, key = levels[i], opts.bucketKeyOrder(levels[i].kind)
+			}
+		}
+
+		if level == nil {
+			funcExpr, ok := expr.(*sqlparser.FuncExpr)
+			switch {
+			case !ok:
+				return unsupportedError("expression", sqlparser.String(expr), "order by must be a group by expression or an aggregate function")
+			case funcExpr.Name.Lowered() == "count" && sqlparser.String(funcExpr.Exprs) == "*":
+				key = "_count"
+			case havingFuncMap[funcExpr.Name.Lowered()]:
+				// the metric may be not in select, and it is needed to be sorted by
+				metric := buildFuncAgg(funcExpr, aliases)
+				innermost.children = innermost.children.add(metric)
+				key = metric.name
+			default:
+				return unsupportedError("function", sqlparser.String(funcExpr), "unsupported function in order by")
+			}
+			level = innermost
+		}
 
+		if !orderableBucketKinds[level.kind] {
+			return unsupportedError("expression", sqlparser.String(orderByExpr), "order by is not supported for "+level.kind+" aggregation")
+		}
+		orders[level] = append(orders[level], msi{key: orderByExpr.Direction})
+	}
+
+	for level, order := range orders {
+		level.params["order"] = order
+	}
+	return nil
+}
+
+// handleAggLimit limits the number of buckets
+// the size of terms is offset + row count, so the first page is complete,
+// the offset and the limit of other bucket aggregations is done by bucket_sort
+// the nested buckets can not be limited as rows, so group by multiple columns needs
+// the composite aggregation for a limit
+func handleAggLimit(limit *sqlparser.Limit, levels []*aggregation, opts *Options) error {
+	if len(levels) > 1 {
+		return unsupportedError("clause", sqlparser.String(limit), "limit of group by multiple columns is not supported, use the composite aggregation")
+	}
+
+	var from, size int
+	var err error
+	if limit.Offset != nil {
+		from, err = buildLimitValue(limit.Offset)
+		if err != nil {
+			return err
+		}
+	}
+	size, err = buildLimitValue(limit.Rowcount)
+	if err != nil {
+		return err
+	}
+
+	var level = levels[0]
+	if level.kind == "terms" {
+		level.params["size"] = from + size
+	}
+	if from > 0 || level.kind != "terms" {
+		if err = opts.checkBucketSort(sqlparser.String(limit), "offset and limit of "+level.kind+" aggregation"); err != nil {
+			return err
+		}
+		level.children = level.children.add(&aggregation{
+			name: bucketSortAggName,
+			kind: "bucket_sort",
+			params: msi{
+				"from": from,
+				"size": size,
+			},
+		})
+	}
+	return nil
+}
+
+// the name of the bucket_sort aggregation built from limit
+const bucketSortAggName = "limit"
+
+func buildAggs(sel *sqlparser.Select, opts *Options) (aggregations, error) {
+
+	funcExprArr, _, funcErr := extractFuncAndColFromSelect(sel.SelectExprs)
 	if funcErr != nil {
+		return nil, funcErr
+	}
+	aliases := buildSelectAliases(sel.SelectExprs)
+
+	// group by may use the aliases in select
+	groupBy := aliases.resolveGroupBy(sel.GroupBy)
+
+	// the group by functions in select are the keys of the buckets, not metrics
+	var metricFuncExprArr []*sqlparser.FuncExpr
+	for _, funcExpr := range funcExprArr {
+		if !isGroupByExpr(funcExpr, groupBy) {
+			metricFuncExprArr = append(metricFuncExprArr, funcExpr)
+		}
+	}
+	innerAggs := handleFuncInSelectAgg(metricFuncExprArr, aliases)
+
+	// having filters the innermost buckets, where the metrics live
+	if sel.Having != nil {
+		if len(sel.GroupBy) == 0 {
+			return nil, unsupportedError("clause", sqlparser.String(sel.Having), "having without group by is not supported")
+		}
+
+		var err error
+		innerAggs, err = handleHavingAgg(sel.Having.Expr, innerAggs, aliases, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CompositeAggregation && len(groupBy) > 0 {
+		compositeAgg, err := handleCompositeAgg(sel, groupBy, innerAggs, aliases, opts)
+		if err != nil {
+			return nil, err
+		}
+		return aggregations{compositeAgg}, nil
 	}
 
-	aggMap, err := handleGroupByAgg(sel.GroupBy, innerAggMap)
+	aggs, levels, err := handleGroupByAgg(groupBy, innerAggs, aliases, opts)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// without group by there is only one row, nothing to sort or limit
+	if len(levels) == 0 {
+		return aggs, nil
+	}
+
+	if len(sel.OrderBy) > 0 {
+		err = handleAggOrderBy(sel.OrderBy, groupBy, levels, aliases, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if sel.Limit != nil {
+		err = handleAggLimit(sel.Limit, levels, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	mapJSON, _ := json.Marshal(aggMap)
+	return aggs, nil
+}
 
-	return string(mapJSON), nil
+func isGroupByExpr(expr sqlparser.Expr, groupBy sqlparser.GroupBy) bool {
+	for _, groupByExpr := range groupBy {
+		if sqlparser.String(groupByExpr) == sqlparser.String(expr) {
+			return true
+		}
+	}
+	return false
 }
 
 // extract func expressions from select exprs
@@ -304,10 +513,13 @@ func extractFuncAndColFromSelect(sqlSelect sqlparser.SelectExprs) ([]*sqlparser.
 		switch expr.Expr.(type) {
 		case *sqlparser.FuncExpr:
 			funcExpr := expr.Expr.(*sqlparser.FuncExpr)
+			if isScriptFunc(funcExpr) {
+				return nil, nil, unsupportedError("function", sqlparser.String(funcExpr), "computed column is not supported in aggregation")
+			}
 			funcArr = append(funcArr, funcExpr)
 
 		case *sqlparser.ColName:
-			continue
+			colArr = append(colArr, expr.Expr.(*sqlparser.ColName))
 		default:
 			//ignore
 		}
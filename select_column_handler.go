@@ -0,0 +1,119 @@
+package elasticsql
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// selectAliases records the aliases of the select expressions
+// the aliases are case insensitive, the same as mysql
+type selectAliases struct {
+	// names maps the select expressions to their aliases
+	names map[string]string
+	// exprs maps the lowered aliases to the select expressions
+	exprs map[string]sqlparser.Expr
+}
+
+func buildSelectAliases(selectExprs sqlparser.SelectExprs) *selectAliases {
+	aliases := &selectAliases{
+		names: make(map[string]string),
+		exprs: make(map[string]sqlparser.Expr),
+	}
+	for _, v := range selectExprs {
+		expr, ok := v.(*sqlparser.AliasedExpr)
+		if !ok || expr.As.IsEmpty() {
+			continue
+		}
+		aliases.names[sqlparser.String(expr.Expr)] = expr.As.String()
+		aliases.exprs[expr.As.Lowered()] = expr.Expr
+	}
+	return aliases
+}
+
+// nameOf returns the alias of expr, or defaultName if expr has no alias
+func (a *selectAliases) nameOf(expr sqlparser.Expr, defaultName string) string {
+	if name, ok := a.names[sqlparser.String(expr)]; ok {
+		return name
+	}
+	return defaultName
+}
+
+// resolve returns the select expression if expr is an alias, otherwise expr itself
+func (a *selectAliases) resolve(expr sqlparser.Expr) sqlparser.Expr {
+	colName, ok := expr.(*sqlparser.ColName)
+	if !ok || !colName.Qualifier.IsEmpty() {
+		return expr
+	}
+	if aliased, ok := a.exprs[colName.Name.Lowered()]; ok {
+		return aliased
+	}
+	return expr
+}
+
+func (a *selectAliases) resolveGroupBy(groupBy sqlparser.GroupBy) sqlparser.GroupBy {
+	var resolved = make(sqlparser.GroupBy, len(groupBy))
+	for i, expr := range groupBy {
+		resolved[i] = a.resolve(expr)
+	}
+	return resolved
+}
+
+// buildSelectColumns records where the value of each select column is in the response
+// the columns of an aggregation query are either the group by expressions or the metrics,
+// the others are the fields of the hits
+func buildSelectColumns(sel *sqlparser.Select, aggFlag bool) []Column {
+	var aliases = buildSelectAliases(sel.SelectExprs)
+	var groupBy = aliases.resolveGroupBy(sel.GroupBy)
+	var columns []Column
+
+	for _, v := range sel.SelectExprs {
+		switch expr := v.(type) {
+		case *sqlparser.StarExpr:
+			if !aggFlag {
+				name := sqlparser.String(expr)
+				columns = append(columns, Column{Name: name, Key: name, Kind: FieldColumn})
+			}
+		case *sqlparser.AliasedExpr:
+			name := strings.Replace(sqlparser.String(expr.Expr), "`", "", -1)
+			if !expr.As.IsEmpty() {
+				name = expr.As.String()
+			}
+
+			if !aggFlag {
+				if colName, ok := expr.Expr.(*sqlparser.ColName); ok {
+					key := strings.Replace(sqlparser.String(colName), "`", "", -1)
+					columns = append(columns, Column{Name: name, Key: key, Kind: FieldColumn})
+				} else if isScriptExpr(expr.Expr) {
+					columns = append(columns, Column{Name: name, Key: buildScriptFieldName(expr), Kind: ScriptColumn})
+				}
+				continue
+			}
+
+			if isGroupByExpr(expr.Expr, groupBy) {
+				columns = append(columns, Column{Name: name, Key: buildGroupByAggName(expr.Expr, aliases), Kind: BucketColumn})
+			} else if funcExpr, ok := expr.Expr.(*sqlparser.FuncExpr); ok {
+				columns = append(columns, Column{Name: name, Key: buildMetricName(funcExpr, aliases), Kind: MetricColumn})
+			}
+		}
+	}
+
+	return columns
+}
+
+// buildBucketNames returns the names of the bucket aggregations of group by in the order of nesting
+func buildBucketNames(sel *sqlparser.Select, opts *Options) []string {
+	if len(sel.GroupBy) == 0 {
+		return nil
+	}
+	if opts.CompositeAggregation {
+		return []string{compositeAggName}
+	}
+
+	var aliases = buildSelectAliases(sel.SelectExprs)
+	var names = make([]string, len(sel.GroupBy))
+	for i, expr := range aliases.resolveGroupBy(sel.GroupBy) {
+		names[i] = buildGroupByAggName(expr, aliases)
+	}
+	return names
+}
@@ -0,0 +1,115 @@
+package elasticsql
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// the name of the composite aggregation built from group by
+const compositeAggName = "composite"
+
+// handleCompositeAgg builds one composite aggregation with a source for each group by expression,
+// the metrics and having are the sub aggregations of the composite buckets
+func handleCompositeAgg(sel *sqlparser.Select, groupBy sqlparser.GroupBy, innerAggs aggregations, aliases *selectAliases, opts *Options) (*aggregation, error) {
+	if err := opts.checkBucketSort(sqlparser.String(groupBy), "composite aggregation"); err != nil {
+		return nil, err
+	}
+
+	var sources = make([]msi, len(groupBy))
+	var sourceParams = make([]msi, len(groupBy))
+
+	for i, v := range groupBy {
+		var source msi
+
+		switch item := v.(type) {
+		case *sqlparser.ColName:
+			sourceParams[i] = msi{"field": item.Name.String()}
+			source = msi{"terms": sourceParams[i]}
+		case *sqlparser.FuncExpr:
+			if item.Name.Lowered() != "date_histogram" {
+				return nil, unsupportedError("function", sqlparser.String(item), "unsupported group by function in composite aggregation")
+			}
+			agg, err := handleGroupByFuncExpr(item, nil, opts)
+			if err != nil {
+				return nil, err
+			}
+			sourceParams[i] = agg.params
+			source = msi{agg.kind: agg.params}
+		default:
+			return nil, unsupportedError("expression", sqlparser.String(v), "unsupported group by expression")
+		}
+
+		sources[i] = msi{buildGroupByAggName(v, aliases): source}
+	}
+
+	// the composite buckets are always sorted by the sources, only their directions can be changed
+	for _, orderByExpr := range sel.OrderBy {
+		var found = false
+		var expr = aliases.resolve(orderByExpr.Expr)
+		for i, groupByExpr := range groupBy {
+			if sqlparser.String(groupByExpr) == sqlparser.String(expr) {
+				sourceParams[i]["order"] = orderByExpr.Direction
+				found = true
+			}
+		}
+		if !found {
+			return nil, unsupportedError("expression", sqlparser.String(expr), "composite aggregation can only be ordered by the group by expressions")
+		}
+	}
+
+	var size = opts.TermsSize
+	if sel.Limit != nil {
+		if sel.Limit.Offset != nil {
+			return nil, unsupportedError("limit", sqlparser.String(sel.Limit), "offset is not supported by composite aggregation, use NextCompositePage instead")
+		}
+		var err error
+		size, err = buildLimitValue(sel.Limit.Rowcount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &aggregation{
+		name: compositeAggName,
+		kind: "composite",
+		params: msi{
+			"size":    size,
+			"sources": sources,
+		},
+		children: innerAggs,
+	}, nil
+}
+
+// NextCompositePage returns the dsl of the next page of a composite aggregation
+// dsl is the previous request built with Options.CompositeAggregation, afterKey is
+// aggregations.composite.after_key of its response
+// there are no more pages when the response has no after_key
+func NextCompositePage(dsl string, afterKey map[string]interface{}) (string, error) {
+	if len(afterKey) == 0 {
+		return "", valueError("after key", "", "after key of composite aggregation is empty")
+	}
+
+	// the numbers of the query are kept as they are, float64 loses the precision of the large integers
+	var request map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(dsl))
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		return "", valueError("dsl", "", err.Error())
+	}
+
+	aggs, _ := request["aggregations"].(map[string]interface{})
+	compositeAgg, _ := aggs[compositeAggName].(map[string]interface{})
+	params, ok := compositeAgg["composite"].(map[string]interface{})
+	if !ok {
+		return "", valueError("dsl", "", "dsl has no composite aggregation")
+	}
+	params["after"] = afterKey
+
+	result, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
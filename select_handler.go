@@ -1,32 +1,27 @@
 package elasticsql
 
 import (
-	"errors"
-	"fmt"
+	"encoding/json"
+	"strconv"
 	"strings"
 
 	"github.com/xwb1989/sqlparser"
 )
 
-func handleSelect(sel *sqlparser.Select) (dsl string, esType string, err error) {
+func handleSelect(sel *sqlparser.Select, opts *Options) (stmt *Statement, err error) {
 
 	// Handle where
-	// top level node pass in an empty interface
-	// to tell the children this is root
-	// is there any better way?
-	var rootParent sqlparser.Expr
-	var defaultQueryMapStr = `{"bool" : {"must": [{"match_all" : {}}]}}`
-	var queryMapStr string
+	var req = searchRequest{Size: opts.DefaultSize}
 
 	// use may not pass where clauses
 	if sel.Where != nil {
-		queryMapStr, err = handleSelectWhere(&sel.Where.Expr, true, &rootParent)
+		req.Query, err = handleSelectWhere(&sel.Where.Expr, true, opts)
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 	}
-	if queryMapStr == "" {
-		queryMapStr = defaultQueryMapStr
+	if req.Query == nil {
+		req.Query = &boolQuery{must: []query{&matchAllQuery{}}}
 	}
 
 	//TODO support multiple tables
@@ -36,72 +31,124 @@ func handleSelect(sel *sqlparser.Select) (dsl string, esType string, err error)
 
 	//Handle from
 	if len(sel.From) != 1 {
-		return "", "", errors.New("elasticsql: multiple from currently not supported")
+		return nil, unsupportedError("table", sqlparser.String(sel.From), "multiple from currently not supported")
 	}
-	esType = sqlparser.String(sel.From)
+	esType := sqlparser.String(sel.From)
 	esType = strings.Replace(esType, "`", "", -1)
 
-	queryFrom, querySize := "0", "1"
-
 	aggFlag := false
 	// if the request is to aggregation
 	// then set aggFlag to true, and querySize to 0
 	// to not return any query result
 
-	var aggStr string
 	if len(sel.GroupBy) > 0 || checkNeedAgg(sel.SelectExprs) {
 		aggFlag = true
-		querySize = "0"
-		aggStr, err = buildAggs(sel)
+		req.Size = 0
+		req.Aggregations, err = buildAggs(sel, opts)
 		if err != nil {
-			//aggStr = ""
-			return "", "", err
+			return nil, err
 		}
 	}
 
 	// Handle limit
-	if sel.Limit != nil {
+	// when executing aggregations, limit is applied to the buckets
+	if sel.Limit != nil && aggFlag == false {
 		if sel.Limit.Offset != nil {
-			queryFrom = sqlparser.String(sel.Limit.Offset)
+			req.From, err = buildLimitValue(sel.Limit.Offset)
+			if err != nil {
+				return nil, err
+			}
+		}
+		req.Size, err = buildLimitValue(sel.Limit.Rowcount)
+		if err != nil {
+			return nil, err
 		}
-		querySize = sqlparser.String(sel.Limit.Rowcount)
 	}
 
-	// Handle order by
-	// when executating aggregations, order by is useless
-	var orderByArr []string
+	// Handle select columns
+	// when executing aggregations, there are no hits to filter
 	if aggFlag == false {
-		for _, orderByExpr := range sel.OrderBy {
-			orderByStr := fmt.Sprintf(`{"%v": "%v"}`, strings.Replace(sqlparser.String(orderByExpr.Expr), "`", "", -1), orderByExpr.Direction)
-			orderByArr = append(orderByArr, orderByStr)
+		sourceFields := buildSourceFields(sel.SelectExprs, esType)
+		if len(sourceFields) > 0 {
+			req.Source = sourceFields
+			// docvalue_fields and stored_fields are named fielddata_fields and fields in 2.x
+			legacyFields := opts.TargetVersion == Elasticsearch2
+			if opts.DocValueFields && legacyFields {
+				req.FielddataFields = sourceFields
+			} else if opts.DocValueFields {
+				req.DocValueFields = sourceFields
+			}
+			if opts.StoredFields && legacyFields {
+				req.Fields = sourceFields
+			} else if opts.StoredFields {
+				req.StoredFields = sourceFields
+			}
 		}
-	}
 
-	var resultMap = map[string]interface{}{
-		"query": queryMapStr,
-		"from":  queryFrom,
-		"size":  querySize,
+		// the computed columns
+		req.ScriptFields, err = buildScriptFields(sel.SelectExprs, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(req.ScriptFields) > 0 && sourceFields != nil && len(sourceFields) == 0 {
+			// only the computed columns are selected, the documents are not needed
+			req.Source = false
+		}
 	}
 
-	if len(aggStr) > 0 {
-		resultMap["aggregations"] = aggStr
+	// Handle order by
+	// when executing aggregations, order by is applied to the buckets
+	if aggFlag == false {
+		aliases := buildSelectAliases(sel.SelectExprs)
+		for _, orderByExpr := range sel.OrderBy {
+			req.Sort = append(req.Sort, sortField{
+				field: strings.Replace(sqlparser.String(aliases.resolve(orderByExpr.Expr)), "`", "", -1),
+				order: orderByExpr.Direction,
+			})
+		}
 	}
 
-	if len(orderByArr) > 0 {
-		resultMap["sort"] = fmt.Sprintf("[%v]", strings.Join(orderByArr, ","))
+	dslBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
 	}
 
-	// keep the travesal in order, avoid unpredicted json
-	var keySlice = []string{"query", "from", "size", "sort", "aggregations"}
-	var resultArr []string
-	for _, mapKey := range keySlice {
-		if val, ok := resultMap[mapKey]; ok {
-			resultArr = append(resultArr, fmt.Sprintf(`"%v" : %v`, mapKey, val))
+	return &Statement{
+		DSL:      string(dslBytes),
+		Table:    esType,
+		Endpoint: SearchEndpoint,
+		Columns:  buildSelectColumns(sel, aggFlag),
+		Buckets:  buildBucketNames(sel, opts),
+	}, nil
+}
+
+// buildSourceFields returns the fields selected by sql, nil means all the fields
+// table.* of the queried table is the same as *, other x.* are the fields of object x
+func buildSourceFields(selectExprs sqlparser.SelectExprs, esType string) []string {
+	var fields = []string{}
+	for _, v := range selectExprs {
+		switch expr := v.(type) {
+		case *sqlparser.StarExpr:
+			if expr.TableName.IsEmpty() || expr.TableName.Name.String() == esType {
+				return nil
+			}
+			fields = append(fields, strings.Replace(sqlparser.String(expr), "`", "", -1))
+		case *sqlparser.AliasedExpr:
+			if colName, ok := expr.Expr.(*sqlparser.ColName); ok {
+				fields = append(fields, strings.Replace(sqlparser.String(colName), "`", "", -1))
+			}
 		}
 	}
+	return fields
+}
 
-	dsl = "{" + strings.Join(resultArr, ",") + "}"
-	return dsl, esType, nil
+// the offset and row count of limit must be integers
+func buildLimitValue(expr sqlparser.Expr) (int, error) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return 0, valueError("limit", sqlparser.String(expr), "limit must be an integer")
+	}
+	return strconv.Atoi(string(val.Val))
 }
 
 // if the where is empty, need to check whether to agg or not
@@ -113,8 +160,8 @@ func checkNeedAgg(sqlSelect sqlparser.SelectExprs) bool {
 			continue
 		}
 
-		//TODO more precise
-		if _, ok := expr.Expr.(*sqlparser.FuncExpr); ok {
+		// the scalar functions are computed by scripts
+		if funcExpr, ok := expr.Expr.(*sqlparser.FuncExpr); ok && !isScriptFunc(funcExpr) {
 			return true
 		}
 	}
@@ -122,208 +169,341 @@ func checkNeedAgg(sqlSelect sqlparser.SelectExprs) bool {
 }
 
 func buildNestedFuncStrValue(nestedFunc *sqlparser.FuncExpr) (string, error) {
-	return "", errors.New("elasticsql: unsupported function" + nestedFunc.Name.String())
+	return "", unsupportedError("function", sqlparser.String(nestedFunc), "unsupported function")
 }
 
-func handleSelectWhereAndExpr(expr *sqlparser.Expr, topLevel bool, parent *sqlparser.Expr) (string, error) {
+func handleSelectWhereAndExpr(expr *sqlparser.Expr, topLevel bool, opts *Options) (query, error) {
 	andExpr := (*expr).(*sqlparser.AndExpr)
 	leftExpr := andExpr.Left
 	rightExpr := andExpr.Right
-	leftStr, err := handleSelectWhere(&leftExpr, false, expr)
+	leftQuery, err := handleSelectWhere(&leftExpr, false, opts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	rightStr, err := handleSelectWhere(&rightExpr, false, expr)
+	rightQuery, err := handleSelectWhere(&rightExpr, false, opts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// not toplevel
-	// if the parent node is also and, then the result can be merged
-
-	var resultStr string
-	if leftStr == "" || rightStr == "" {
-		resultStr = leftStr + rightStr
-	} else {
-		resultStr = leftStr + `,` + rightStr
+	// if the child node is also and, then the result can be merged
+	var must []query
+	for _, child := range []query{leftQuery, rightQuery} {
+		if childBool, ok := child.(*boolQuery); ok && childBool.op == andOp {
+			must = append(must, childBool.must...)
+		} else if child != nil {
+			must = append(must, child)
+		}
 	}
 
-	if _, ok := (*parent).(*sqlparser.AndExpr); ok {
-		return resultStr, nil
-	}
-	return fmt.Sprintf(`{"bool" : {"must" : [%v]}}`, resultStr), nil
+	return &boolQuery{must: must, op: andOp}, nil
 }
 
-func handleSelectWhereOrExpr(expr *sqlparser.Expr, topLevel bool, parent *sqlparser.Expr) (string, error) {
+func handleSelectWhereOrExpr(expr *sqlparser.Expr, topLevel bool, opts *Options) (query, error) {
 	orExpr := (*expr).(*sqlparser.OrExpr)
 	leftExpr := orExpr.Left
 	rightExpr := orExpr.Right
 
-	leftStr, err := handleSelectWhere(&leftExpr, false, expr)
+	leftQuery, err := handleSelectWhere(&leftExpr, false, opts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	rightStr, err := handleSelectWhere(&rightExpr, false, expr)
+	rightQuery, err := handleSelectWhere(&rightExpr, false, opts)
 	if err != nil {
-		return "", err
-	}
-
-	var resultStr string
-	if leftStr == "" || rightStr == "" {
-		resultStr = leftStr + rightStr
-	} else {
-		resultStr = leftStr + `,` + rightStr
+		return nil, err
 	}
 
-	// not toplevel
-	// if the parent node is also or node, then merge the query param
-	if _, ok := (*parent).(*sqlparser.OrExpr); ok {
-		return resultStr, nil
+	// if the child node is also or node, then merge the query param
+	var should []query
+	for _, child := range []query{leftQuery, rightQuery} {
+		if childBool, ok := child.(*boolQuery); ok && childBool.op == orOp {
+			should = append(should, childBool.should...)
+		} else if child != nil {
+			should = append(should, child)
+		}
 	}
 
-	return fmt.Sprintf(`{"bool" : {"should" : [%v]}}`, resultStr), nil
+	return &boolQuery{should: should, op: orOp}, nil
 }
 
-func buildComparisonExprRightStr(expr sqlparser.Expr) (string, bool, error) {
-	var rightStr string
-	var err error
+func buildComparisonExprRightValue(expr sqlparser.Expr) (interface{}, bool, error) {
 	var missingCheck = false
 	switch expr.(type) {
-	case *sqlparser.SQLVal:
-		rightStr = sqlparser.String(expr)
-		rightStr = strings.Trim(rightStr, `'`)
 	case *sqlparser.GroupConcatExpr:
-		return "", missingCheck, errors.New("elasticsql: group_concat not supported")
+		return nil, missingCheck, unsupportedError("function", sqlparser.String(expr), "group_concat not supported")
 	case *sqlparser.FuncExpr:
 		// parse nested
 		funcExpr := expr.(*sqlparser.FuncExpr)
-		rightStr, err = buildNestedFuncStrValue(funcExpr)
+		rightStr, err := buildNestedFuncStrValue(funcExpr)
 		if err != nil {
-			return "", missingCheck, err
+			return nil, missingCheck, err
 		}
+		return rightStr, missingCheck, nil
 	case *sqlparser.ColName:
 		if strings.ToLower(sqlparser.String(expr)) == "missing" {
 			missingCheck = true
-			return "", missingCheck, nil
+			return nil, missingCheck, nil
 		}
 
-		return "", missingCheck, errors.New("elasticsql: column name on the right side of compare operator is not supported")
-	case sqlparser.ValTuple:
-		rightStr = sqlparser.String(expr)
-	default:
-		// cannot reach here
+		return nil, missingCheck, unsupportedError("expression", sqlparser.String(expr), "column name on the right side of compare operator is not supported")
 	}
-	return rightStr, missingCheck, err
+
+	rightVal, err := buildValue(expr)
+	return rightVal, missingCheck, err
 }
 
-func unescapeSql(sql, escapeStr string) string {
-	resSql := ""
-	strSegments := strings.Split(sql, escapeStr)
-	for _, segment := range strSegments {
-		if segment == "" {
-			resSql += escapeStr // continuous escapeStr, only remove the first one
-		} else {
-			resSql += segment
+// the values of in (...) keep their sql types, numbers stay numbers
+func buildComparisonExprRightList(expr sqlparser.Expr) ([]interface{}, error) {
+	valTuple, ok := expr.(sqlparser.ValTuple)
+	if !ok {
+		return nil, unsupportedError("expression", sqlparser.String(expr), "the right side of in must be a value list")
+	}
+
+	var values = make([]interface{}, 0, len(valTuple))
+	for _, valExpr := range valTuple {
+		val, err := buildValue(valExpr)
+		if err != nil {
+			return nil, err
 		}
+		values = append(values, val)
 	}
-	return resSql
+	return values, nil
 }
 
-func handleSelectWhereComparisonExpr(expr *sqlparser.Expr, topLevel bool, parent *sqlparser.Expr) (string, error) {
-	comparisonExpr := (*expr).(*sqlparser.ComparisonExpr)
+func handleSelectWhereComparisonExpr(expr *sqlparser.Expr, topLevel bool, opts *Options) (query, error) {
+	comparisonExpr := normalizeComparisonExpr((*expr).(*sqlparser.ComparisonExpr))
+
+	if isScriptComparison(comparisonExpr) {
+		resultQuery, err := handleSelectWhereScriptExpr(comparisonExpr, opts)
+		if err != nil {
+			return nil, err
+		}
+		if topLevel {
+			resultQuery = &boolQuery{must: []query{resultQuery}}
+		}
+		return resultQuery, nil
+	}
+
 	colName, ok := comparisonExpr.Left.(*sqlparser.ColName)
 
 	if !ok {
-		return "", errors.New("elasticsql: invalid comparison expression, the left must be a column name")
+		return nil, unsupportedError("expression", sqlparser.String(comparisonExpr), "the left of comparison must be a column name")
 	}
 
 	colNameStr := sqlparser.String(colName)
 	colNameStr = strings.Replace(colNameStr, "`", "", -1)
-	rightStr, missingCheck, err := buildComparisonExprRightStr(comparisonExpr.Right)
+
+	var resultQuery query
+	switch comparisonExpr.Operator {
+	case "in", "not in":
+		values, err := buildComparisonExprRightList(comparisonExpr.Right)
+		if err != nil {
+			return nil, err
+		}
+		resultQuery = &termsQuery{field: colNameStr, values: values}
+		if comparisonExpr.Operator == "not in" {
+			resultQuery = &boolQuery{mustNot: []query{resultQuery}}
+		}
+	case "like", "not like":
+		var err error
+		resultQuery, err = handleSelectWhereLikeExpr(colNameStr, comparisonExpr, opts)
+		if err != nil {
+			return nil, err
+		}
+		if comparisonExpr.Operator == "not like" {
+			resultQuery = &boolQuery{mustNot: []query{resultQuery}}
+		}
+	case "regexp", "not regexp":
+		var err error
+		resultQuery, err = handleSelectWhereRegexpExpr(colNameStr, comparisonExpr, opts)
+		if err != nil {
+			return nil, err
+		}
+		if comparisonExpr.Operator == "not regexp" {
+			resultQuery = &boolQuery{mustNot: []query{resultQuery}}
+		}
+	default:
+		rightVal, missingCheck, err := buildComparisonExprRightValue(comparisonExpr.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch comparisonExpr.Operator {
+		case ">=", "<=":
+			resultQuery = &rangeQuery{field: colNameStr, bounds: msi{opts.rangeBound(comparisonExpr.Operator): rightVal}}
+		case "=":
+			// field is missing
+			if missingCheck { // missing was deprecated in 2.2, use exists instead.
+				resultQuery = &boolQuery{mustNot: []query{&existsQuery{field: colNameStr}}}
+			} else {
+				resultQuery = buildEqualityQuery(colNameStr, rightVal, opts)
+			}
+		case ">":
+			resultQuery = &rangeQuery{field: colNameStr, bounds: msi{"gt": rightVal}}
+		case "<":
+			resultQuery = &rangeQuery{field: colNameStr, bounds: msi{"lt": rightVal}}
+		case "!=":
+			if missingCheck { // missing was deprecated in 2.2, use exists instead.
+				resultQuery = &boolQuery{must: []query{&existsQuery{field: colNameStr}}}
+			} else {
+				resultQuery = &boolQuery{mustNot: []query{buildEqualityQuery(colNameStr, rightVal, opts)}}
+			}
+		default:
+			return nil, unsupportedError("operator", comparisonExpr.Operator, "unsupported comparison operator")
+		}
+	}
+
+	// the root node need to have bool and must
+	if topLevel {
+		resultQuery = &boolQuery{must: []query{resultQuery}}
+	}
+
+	return resultQuery, nil
+}
+
+// the query of = depends on the equality option and the type of the field
+func buildEqualityQuery(field string, val interface{}, opts *Options) query {
+	if opts.equalityOf(field) == TermEquality {
+		return &termQuery{field: field, value: val}
+	}
+	return &matchPhraseQuery{field: field, query: val}
+}
+
+func handleSelectWhereNotExpr(expr *sqlparser.Expr, topLevel bool, opts *Options) (query, error) {
+	notExpr := (*expr).(*sqlparser.NotExpr)
+	innerExpr := stripParenExpr(notExpr.Expr)
+
+	// not not a is just a, so the inner expression
+	// takes the place of this node in the tree
+	if doubleNotExpr, ok := innerExpr.(*sqlparser.NotExpr); ok {
+		innerExpr = doubleNotExpr.Expr
+		return handleSelectWhere(&innerExpr, topLevel, opts)
+	}
+
+	// the must_not wraps the inner node,
+	// so and/or children will not be merged into the outer node
+	innerQuery, err := handleSelectWhere(&innerExpr, false, opts)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if innerQuery == nil {
+		return nil, nil
 	}
 
-	// unescape rightStr
-	escapeStr := sqlparser.String(comparisonExpr.Escape)
-	escapeStr = strings.Trim(escapeStr, "'") // remove quote both sides
-	rightStr = unescapeSql(rightStr, escapeStr)
-	resultStr := ""
+	return &boolQuery{mustNot: []query{innerQuery}}, nil
+}
 
-	// allow eq empty string
-	if rightStr == `<nil>` {
-		rightStr = ""
+// remove all the parens around the expression
+func stripParenExpr(expr sqlparser.Expr) sqlparser.Expr {
+	for {
+		parenExpr, ok := expr.(*sqlparser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = parenExpr.Expr
 	}
+}
 
-	switch comparisonExpr.Operator {
-	case ">=":
-		resultStr = fmt.Sprintf(`{"range" : {"%v" : {"from" : "%v"}}}`, colNameStr, rightStr)
-	case "<=":
-		resultStr = fmt.Sprintf(`{"range" : {"%v" : {"to" : "%v"}}}`, colNameStr, rightStr)
-	case "=":
-		// field is missing
-		if missingCheck { // missing was deprecated in 2.2, use exists instead.
-			resultStr = fmt.Sprintf(`{"bool" : {"must_not" : [{"exists":{"field":"%v"}}]}}`, colNameStr)
-		} else {
-			resultStr = fmt.Sprintf(`{"match_phrase" : {"%v" : {"query" : "%v"}}}`, colNameStr, rightStr)
-		}
-	case ">":
-		resultStr = fmt.Sprintf(`{"range" : {"%v" : {"gt" : "%v"}}}`, colNameStr, rightStr)
-	case "<":
-		resultStr = fmt.Sprintf(`{"range" : {"%v" : {"lt" : "%v"}}}`, colNameStr, rightStr)
-	case "!=":
-		if missingCheck { // missing was deprecated in 2.2, use exists instead.
-			resultStr = fmt.Sprintf(`{"bool" : {"must" : [{"exists":{"field":"%v"}}]}}`, colNameStr)
-		} else {
-			resultStr = fmt.Sprintf(`{"bool" : {"must_not" : [{"match_phrase" : {"%v" : {"query" : "%v"}}}]}}`, colNameStr, rightStr)
-		}
-	case "in":
-		// the default valTuple is ('1', '2', '3') like
-		// so need to drop the () and replace ' to "
-		rightStr = strings.Replace(rightStr, `'`, `"`, -1)
-		rightStr = strings.Trim(rightStr, "(")
-		rightStr = strings.Trim(rightStr, ")")
-		resultStr = fmt.Sprintf(`{"terms" : {"%v" : [%v]}}`, colNameStr, rightStr)
-	case "like":
-		rightStr = strings.Replace(rightStr, `%`, ``, -1)
-		resultStr = fmt.Sprintf(`{"match_phrase" : {"%v" : {"query" : "%v"}}}`, colNameStr, rightStr)
-	case "not like":
-		rightStr = strings.Replace(rightStr, `%`, ``, -1)
-		resultStr = fmt.Sprintf(`{"bool" : {"must_not" : {"match_phrase" : {"%v" : {"query" : "%v"}}}}}`, colNameStr, rightStr)
-	case "not in":
-		// the default valTuple is ('1', '2', '3') like
-		// so need to drop the () and replace ' to "
-		rightStr = strings.Replace(rightStr, `'`, `"`, -1)
-		rightStr = strings.Trim(rightStr, "(")
-		rightStr = strings.Trim(rightStr, ")")
-		resultStr = fmt.Sprintf(`{"bool" : {"must_not" : {"terms" : {"%v" : [%v]}}}}`, colNameStr, rightStr)
+func handleSelectWhereIsExpr(expr *sqlparser.Expr, topLevel bool, opts *Options) (query, error) {
+	isExpr := (*expr).(*sqlparser.IsExpr)
+	colName, ok := isExpr.Expr.(*sqlparser.ColName)
+
+	if !ok {
+		return nil, unsupportedError("expression", sqlparser.String(isExpr), "the left of is must be a column name")
+	}
+
+	colNameStr := sqlparser.String(colName)
+	colNameStr = strings.Replace(colNameStr, "`", "", -1)
+	var resultQuery query
+
+	switch isExpr.Operator {
+	case sqlparser.IsNullStr:
+		resultQuery = &boolQuery{mustNot: []query{&existsQuery{field: colNameStr}}}
+	case sqlparser.IsNotNullStr:
+		resultQuery = &existsQuery{field: colNameStr}
+	case sqlparser.IsTrueStr:
+		resultQuery = &termQuery{field: colNameStr, value: true}
+	case sqlparser.IsNotTrueStr:
+		resultQuery = &boolQuery{mustNot: []query{&termQuery{field: colNameStr, value: true}}}
+	case sqlparser.IsFalseStr:
+		resultQuery = &termQuery{field: colNameStr, value: false}
+	case sqlparser.IsNotFalseStr:
+		resultQuery = &boolQuery{mustNot: []query{&termQuery{field: colNameStr, value: false}}}
+	default:
+		return nil, unsupportedError("operator", isExpr.Operator, "unsupported is expression")
 	}
 
 	// the root node need to have bool and must
 	if topLevel {
-		resultStr = fmt.Sprintf(`{"bool" : {"must" : [%v]}}`, resultStr)
+		resultQuery = &boolQuery{must: []query{resultQuery}}
+	}
+
+	return resultQuery, nil
+}
+
+func handleSelectWhereMultiMatch(funcExpr *sqlparser.FuncExpr) (query, error) {
+	params := funcExpr.Exprs
+	if len(params) > 3 || len(params) < 2 {
+		return nil, unsupportedError("function", sqlparser.String(funcExpr), "the multi_match must have 2 or 3 params, (query, fields and type) or (query, fields)")
+	}
+
+	var multiMatch = &multiMatchQuery{}
+	for i := 0; i < len(params); i++ {
+		// a = b
+		aliasedExpr, ok := params[i].(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, unsupportedError("param", sqlparser.String(params[i]), "the param should be query = xxx, field = yyy, type = zzz")
+		}
+		kv, ok := aliasedExpr.Expr.(*sqlparser.ComparisonExpr)
+		if !ok || kv.Operator != "=" {
+			return nil, unsupportedError("param", sqlparser.String(params[i]), "the param should be query = xxx, field = yyy, type = zzz")
+		}
+		k := strings.TrimSpace(strings.Replace(sqlparser.String(kv.Left), "`", "", -1))
+		switch k {
+		case "type":
+			multiMatch.typ = buildMultiMatchParamStr(kv.Right)
+		case "query":
+			multiMatch.query = buildMultiMatchParamStr(kv.Right)
+		case "fields":
+			fieldList, ok := kv.Right.(sqlparser.ValTuple)
+			if !ok {
+				fieldList = sqlparser.ValTuple{stripParenExpr(kv.Right)}
+			}
+			for _, field := range fieldList {
+				multiMatch.fields = append(multiMatch.fields, buildMultiMatchParamStr(field))
+			}
+		default:
+			return nil, unsupportedError("param", sqlparser.String(params[i]), "unknown param for multi_match")
+		}
 	}
+	return multiMatch, nil
+}
 
-	return resultStr, nil
+// the params of multi_match may be either quoted or bare words
+func buildMultiMatchParamStr(expr sqlparser.Expr) string {
+	if val, ok := expr.(*sqlparser.SQLVal); ok {
+		return string(val.Val)
+	}
+	return strings.Replace(sqlparser.String(expr), "`", "", -1)
 }
 
-func handleSelectWhere(expr *sqlparser.Expr, topLevel bool, parent *sqlparser.Expr) (string, error) {
+func handleSelectWhere(expr *sqlparser.Expr, topLevel bool, opts *Options) (query, error) {
 	if expr == nil {
-		return "", errors.New("elasticsql: error expression cannot be nil here")
+		return nil, unsupportedError("expression", "", "error expression cannot be nil here")
 	}
 
 	switch e := (*expr).(type) {
 	case *sqlparser.AndExpr:
-		return handleSelectWhereAndExpr(expr, topLevel, parent)
+		return handleSelectWhereAndExpr(expr, topLevel, opts)
 
 	case *sqlparser.OrExpr:
-		return handleSelectWhereOrExpr(expr, topLevel, parent)
+		return handleSelectWhereOrExpr(expr, topLevel, opts)
 	case *sqlparser.ComparisonExpr:
-		return handleSelectWhereComparisonExpr(expr, topLevel, parent)
+		return handleSelectWhereComparisonExpr(expr, topLevel, opts)
 
 	case *sqlparser.IsExpr:
-		return "", errors.New("elasticsql: is expression currently not supported")
+		return handleSelectWhereIsExpr(expr, topLevel, opts)
 	case *sqlparser.RangeCond:
 		// between a and b
 		// the meaning is equal to range query
@@ -331,20 +511,26 @@ func handleSelectWhere(expr *sqlparser.Expr, topLevel bool, parent *sqlparser.Ex
 		colName, ok := rangeCond.Left.(*sqlparser.ColName)
 
 		if !ok {
-			return "", errors.New("elasticsql: range column name missing")
+			return nil, unsupportedError("expression", sqlparser.String(rangeCond), "the left of between must be a column name")
 		}
 
 		colNameStr := sqlparser.String(colName)
 		colNameStr = strings.Replace(colNameStr, "`", "", -1)
-		fromStr := strings.Trim(sqlparser.String(rangeCond.From), `'`)
-		toStr := strings.Trim(sqlparser.String(rangeCond.To), `'`)
+		fromVal, err := buildValue(rangeCond.From)
+		if err != nil {
+			return nil, err
+		}
+		toVal, err := buildValue(rangeCond.To)
+		if err != nil {
+			return nil, err
+		}
 
-		resultStr := fmt.Sprintf(`{"range" : {"%v" : {"from" : "%v", "to" : "%v"}}}`, colNameStr, fromStr, toStr)
+		var resultQuery query = &rangeQuery{field: colNameStr, bounds: msi{opts.rangeBound(">="): fromVal, opts.rangeBound("<="): toVal}}
 		if topLevel {
-			resultStr = fmt.Sprintf(`{"bool" : {"must" : [%v]}}`, resultStr)
+			resultQuery = &boolQuery{must: []query{resultQuery}}
 		}
 
-		return resultStr, nil
+		return resultQuery, nil
 
 	case *sqlparser.ParenExpr:
 		parentBoolExpr := (*expr).(*sqlparser.ParenExpr)
@@ -355,49 +541,17 @@ func handleSelectWhere(expr *sqlparser.Expr, topLevel bool, parent *sqlparser.Ex
 		if topLevel {
 			isThisTopLevel = true
 		}
-		return handleSelectWhere(&boolExpr, isThisTopLevel, parent)
+		return handleSelectWhere(&boolExpr, isThisTopLevel, opts)
 	case *sqlparser.NotExpr:
-		return "", errors.New("elasticsql: not expression currently not supported")
+		return handleSelectWhereNotExpr(expr, topLevel, opts)
 	case *sqlparser.FuncExpr:
 		switch e.Name.Lowered() {
 		case "multi_match":
-			params := e.Exprs
-			if len(params) > 3 || len(params) < 2 {
-				return "", errors.New("elasticsql: the multi_match must have 2 or 3 params, (query, fields and type) or (query, fields)")
-			}
-
-			var typ, query, fields string
-			for i := 0; i < len(params); i++ {
-				elem := strings.Replace(sqlparser.String(params[i]), "`", "", -1) // a = b
-				kv := strings.Split(elem, "=")
-				if len(kv) != 2 {
-					return "", errors.New("elasticsql: the param should be query = xxx, field = yyy, type = zzz")
-				}
-				k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
-				switch k {
-				case "type":
-					typ = strings.Replace(v, "'", "", -1)
-				case "query":
-					query = strings.Replace(v, "`", "", -1)
-					query = strings.Replace(query, "'", "", -1)
-				case "fields":
-					fieldList := strings.Split(strings.TrimRight(strings.TrimLeft(v, "("), ")"), ",")
-					for idx, field := range fieldList {
-						fieldList[idx] = fmt.Sprintf(`"%v"`, strings.TrimSpace(field))
-					}
-					fields = strings.Join(fieldList, ",")
-				default:
-					return "", errors.New("elaticsql: unknow param for multi_match")
-				}
-			}
-			if typ == "" {
-				return fmt.Sprintf(`{"multi_match" : {"query" : "%v", "fields" : [%v]}}`, query, fields), nil
-			}
-			return fmt.Sprintf(`{"multi_match" : {"query" : "%v", "type" : "%v", "fields" : [%v]}}`, query, typ, fields), nil
+			return handleSelectWhereMultiMatch(e)
 		default:
-			return "", errors.New("elaticsql: function in where not supported" + e.Name.Lowered())
+			return nil, unsupportedError("function", sqlparser.String(e), "function in where not supported")
 		}
 	}
 
-	return "", errors.New("elaticsql: logically cannot reached here")
+	return nil, unsupportedError("expression", sqlparser.String(*expr), "unsupported expression in where")
 }
@@ -0,0 +1,172 @@
+package elasticsql
+
+import (
+	"fmt"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// the name of the bucket_selector aggregation built from having
+const havingAggName = "having"
+
+// the aggregate functions which have a single value and can be used in buckets_path
+var havingFuncMap = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// havingScript collects the variables of the bucket_selector script
+type havingScript struct {
+	// bucketsPath maps the script variables to the aggregation paths
+	bucketsPath msi
+	// vars maps the aggregation paths back to the variables, so each path gets one variable
+	vars map[string]string
+	// metrics are the aggregations the script refers to
+	metrics aggregations
+	// aliases of select can be used in having
+	aliases *selectAliases
+	// varPrefix is the prefix of the variables in the script
+	varPrefix string
+}
+
+// handleHavingAgg adds a bucket_selector to the metric aggregations of the innermost bucket
+// the aggregate functions only used in having are added as metrics too
+func handleHavingAgg(expr sqlparser.Expr, innerAggs aggregations, aliases *selectAliases, opts *Options) (aggregations, error) {
+	h := &havingScript{
+		bucketsPath: make(msi),
+		vars:        make(map[string]string),
+		aliases:     aliases,
+		varPrefix:   opts.bucketPathPrefix(),
+	}
+	script, err := h.buildCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, metric := range h.metrics {
+		innerAggs = innerAggs.add(metric)
+	}
+
+	return innerAggs.add(&aggregation{
+		name: havingAggName,
+		kind: "bucket_selector",
+		params: msi{
+			"buckets_path": h.bucketsPath,
+			"script":       script,
+		},
+	}), nil
+}
+
+func (h *havingScript) buildCondition(expr sqlparser.Expr) (string, error) {
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		return h.buildBinaryCondition(e.Left, "&&", e.Right)
+	case *sqlparser.OrExpr:
+		return h.buildBinaryCondition(e.Left, "||", e.Right)
+	case *sqlparser.NotExpr:
+		inner, err := h.buildCondition(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "!(" + inner + ")", nil
+	case *sqlparser.ParenExpr:
+		inner, err := h.buildCondition(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "(" + inner + ")", nil
+	case *sqlparser.ComparisonExpr:
+		operator, ok := painlessOperatorMap[e.Operator]
+		if !ok {
+			return "", unsupportedError("operator", e.Operator, "unsupported operator in having")
+		}
+		left, err := h.buildOperand(e.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := h.buildOperand(e.Right)
+		if err != nil {
+			return "", err
+		}
+		return left + " " + operator + " " + right, nil
+	}
+
+	return "", unsupportedError("expression", sqlparser.String(expr), "unsupported expression in having")
+}
+
+func (h *havingScript) buildBinaryCondition(leftExpr sqlparser.Expr, operator string, rightExpr sqlparser.Expr) (string, error) {
+	left, err := h.buildCondition(leftExpr)
+	if err != nil {
+		return "", err
+	}
+	right, err := h.buildCondition(rightExpr)
+	if err != nil {
+		return "", err
+	}
+	return left + " " + operator + " " + right, nil
+}
+
+func (h *havingScript) buildOperand(expr sqlparser.Expr) (string, error) {
+	switch e := h.aliases.resolve(expr).(type) {
+	case *sqlparser.FuncExpr:
+		return h.buildFuncVar(e)
+	case *sqlparser.ParenExpr:
+		inner, err := h.buildOperand(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "(" + inner + ")", nil
+	case *sqlparser.BinaryExpr:
+		switch e.Operator {
+		case sqlparser.PlusStr, sqlparser.MinusStr, sqlparser.MultStr, sqlparser.DivStr, sqlparser.ModStr:
+		default:
+			return "", unsupportedError("operator", e.Operator, "unsupported operator in having")
+		}
+		left, err := h.buildOperand(e.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := h.buildOperand(e.Right)
+		if err != nil {
+			return "", err
+		}
+		return left + " " + e.Operator + " " + right, nil
+	}
+
+	// only numbers can be compared with the metrics
+	val, err := buildValue(expr)
+	if err != nil {
+		return "", err
+	}
+	switch val.(type) {
+	case int64, uint64, float64:
+		return buildValueStr(val), nil
+	}
+	return "", valueError("number", sqlparser.String(expr), "only numbers can be used in having")
+}
+
+// buildFuncVar returns the script variable of the aggregate function
+func (h *havingScript) buildFuncVar(funcExpr *sqlparser.FuncExpr) (string, error) {
+	if !havingFuncMap[funcExpr.Name.Lowered()] {
+		return "", unsupportedError("function", sqlparser.String(funcExpr), "unsupported function in having")
+	}
+
+	// the doc count of the bucket is the same as count(*)
+	path := "_count"
+	if sqlparser.String(funcExpr.Exprs) != "*" {
+		metric := buildFuncAgg(funcExpr, h.aliases)
+		h.metrics = h.metrics.add(metric)
+		path = metric.name
+	}
+
+	if v, ok := h.vars[path]; ok {
+		return h.varPrefix + v, nil
+	}
+	v := fmt.Sprintf("p%d", len(h.vars))
+	h.vars[path] = v
+	h.bucketsPath[v] = path
+	return h.varPrefix + v, nil
+}
@@ -0,0 +1,179 @@
+package elasticsql
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// the escape character of like when there is no escape clause, same as mysql
+const defaultLikeEscape = '\\'
+
+// keepLikeEscapes keeps the \ of \% and \_ in the strings of the sql, the same as mysql,
+// the tokenizer of sqlparser drops it and like 'a\_b' would match any character instead of _
+func keepLikeEscapes(sql string) string {
+	if !strings.Contains(sql, `\`) {
+		return sql
+	}
+	var result strings.Builder
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote == 0 && (c == '#' || strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "//")):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			result.WriteString(sql[i : i+end])
+			i += end - 1
+			continue
+		case quote == 0 && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 2
+			} else {
+				end += 2
+			}
+			result.WriteString(sql[i : i+2+end])
+			i += 2 + end - 1
+			continue
+		case quote == 0 && (c == '\'' || c == '"' || c == '`'):
+			quote = c
+		case quote != 0 && quote != '`' && c == '\\' && i+1 < len(sql):
+			result.WriteByte(c)
+			i++
+			c = sql[i]
+			if c == '%' || c == '_' {
+				result.WriteByte('\\')
+			}
+		case c == quote:
+			// a doubled quote closes the string and opens it again
+			quote = 0
+		}
+		result.WriteByte(c)
+	}
+	return result.String()
+}
+
+// likeToken is either a literal text or a wildcard of the like pattern
+type likeToken struct {
+	// wildcard is '%' or '_', 0 for literal text
+	wildcard rune
+	text     string
+}
+
+// split the like pattern into literal texts and wildcards
+// the character after escape is always literal
+func parseLikePattern(pattern string, escape rune) []likeToken {
+	var tokens []likeToken
+	var literal strings.Builder
+
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, likeToken{text: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == escape && i+1 < len(runes):
+			i++
+			literal.WriteRune(runes[i])
+		case r == '%' || r == '_':
+			flushLiteral()
+			tokens = append(tokens, likeToken{wildcard: r})
+		default:
+			literal.WriteRune(r)
+		}
+	}
+	flushLiteral()
+
+	return tokens
+}
+
+// the wildcard query uses * and ?, so the literal ones need to be escaped
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+func buildWildcardPattern(tokens []likeToken) string {
+	var pattern strings.Builder
+	for _, token := range tokens {
+		switch token.wildcard {
+		case '%':
+			pattern.WriteString("*")
+		case '_':
+			pattern.WriteString("?")
+		default:
+			pattern.WriteString(wildcardEscaper.Replace(token.text))
+		}
+	}
+	return pattern.String()
+}
+
+// handleSelectWhereLikeExpr chooses the query by the shape of the pattern
+//
+//	abc        the same as equal
+//	abc%       prefix query
+//	%abc%      match_phrase for analyzed fields, wildcard for the term level fields
+//	%          exists query
+//	the others wildcard query, % becomes * and _ becomes ?
+func handleSelectWhereLikeExpr(colNameStr string, comparisonExpr *sqlparser.ComparisonExpr, opts *Options) (query, error) {
+	patternVal, err := buildValue(comparisonExpr.Right)
+	if err != nil {
+		return nil, err
+	}
+	pattern, ok := patternVal.(string)
+	if !ok {
+		return nil, valueError("pattern", sqlparser.String(comparisonExpr.Right), "the pattern of like must be a string")
+	}
+
+	var escape rune = defaultLikeEscape
+	if comparisonExpr.Escape != nil {
+		escapeVal, err := buildValue(comparisonExpr.Escape)
+		if err != nil {
+			return nil, err
+		}
+		escapeStr, ok := escapeVal.(string)
+		if !ok || utf8.RuneCountInString(escapeStr) != 1 {
+			return nil, valueError("escape", sqlparser.String(comparisonExpr.Escape), "the escape of like must be a single character")
+		}
+		escape, _ = utf8.DecodeRuneInString(escapeStr)
+	}
+
+	tokens := parseLikePattern(pattern, escape)
+
+	var literals []likeToken
+	var onlyPercent = true
+	for _, token := range tokens {
+		if token.wildcard == 0 {
+			literals = append(literals, token)
+		} else if token.wildcard != '%' {
+			onlyPercent = false
+		}
+	}
+
+	switch {
+	case len(tokens) == 0:
+		// like '' is the same as = ''
+		return buildEqualityQuery(colNameStr, "", opts), nil
+	case len(literals) == 0 && onlyPercent:
+		// like '%' matches any value
+		return &existsQuery{field: colNameStr}, nil
+	case len(tokens) == 1 && len(literals) == 1:
+		return buildEqualityQuery(colNameStr, literals[0].text, opts), nil
+	case len(literals) == 1 && onlyPercent && tokens[0].wildcard == 0:
+		// abc% or abc%%
+		return &prefixQuery{field: colNameStr, prefix: literals[0].text}, nil
+	case len(literals) == 1 && onlyPercent && tokens[0].wildcard != 0 && tokens[len(tokens)-1].wildcard != 0:
+		// %abc%
+		if opts.equalityOf(colNameStr) == MatchPhraseEquality {
+			return &matchPhraseQuery{field: colNameStr, query: literals[0].text}, nil
+		}
+	}
+
+	return &wildcardQuery{field: colNameStr, pattern: buildWildcardPattern(tokens)}, nil
+}
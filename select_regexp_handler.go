@@ -0,0 +1,154 @@
+package elasticsql
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// the lucene regexp has no \d, \w and \s, use the classes with the same meaning
+var regexpClassMap = map[byte]string{
+	'd': "0-9",
+	'w': "a-zA-Z0-9_",
+	's': " \t\n\r",
+}
+
+// walkRegexp calls fn with the index and the group depth of each character of the pattern,
+// which is not escaped or in a bracket expression
+func walkRegexp(pattern string, fn func(i, depth int)) {
+	var depth = 0
+	var inBracket = false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case inBracket:
+			if c == ']' {
+				inBracket = false
+			}
+		case c == '[':
+			inBracket = true
+			// the ] right after [ or [^ is a literal
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+			}
+		default:
+			if c == ')' {
+				depth--
+			}
+			fn(i, depth)
+			if c == '(' {
+				depth++
+			}
+		}
+	}
+}
+
+// split the pattern by the | which are not in group, bracket or escaped
+func splitRegexpAlternatives(pattern string) []string {
+	var alternatives []string
+	var start = 0
+	walkRegexp(pattern, func(i, depth int) {
+		if pattern[i] == '|' && depth == 0 {
+			alternatives = append(alternatives, pattern[start:i])
+			start = i + 1
+		}
+	})
+	return append(alternatives, pattern[start:])
+}
+
+// translate the escapes of mysql regexp to the lucene syntax
+func translateRegexpEscapes(pattern string) string {
+	var result strings.Builder
+	var inBracket = false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			class, ok := regexpClassMap[pattern[i]]
+			switch {
+			case ok && inBracket:
+				result.WriteString(class)
+			case ok:
+				result.WriteString("[" + class + "]")
+			default:
+				result.WriteByte('\\')
+				result.WriteByte(pattern[i])
+			}
+			continue
+		case c == '"':
+			// " starts a quoted string in lucene
+			result.WriteString(`\"`)
+			continue
+		case c == '[' && !inBracket:
+			inBracket = true
+		case c == ']' && inBracket:
+			inBracket = false
+		}
+		result.WriteByte(c)
+	}
+	return result.String()
+}
+
+// buildRegexpPattern converts a mysql regexp to a lucene one
+// mysql matches anywhere in the value unless anchored by ^ and $,
+// while lucene always matches the whole value, so the anchors can only be
+// at the start and the end of the alternatives, where they are removed
+func buildRegexpPattern(pattern string) (string, error) {
+	alternatives := splitRegexpAlternatives(pattern)
+	for i, alternative := range alternatives {
+		var anchoredStart, anchoredEnd, misplaced bool
+		walkRegexp(alternative, func(j, depth int) {
+			switch {
+			case alternative[j] == '^' && j == 0:
+				anchoredStart = true
+			case alternative[j] == '$' && j == len(alternative)-1:
+				anchoredEnd = true
+			case alternative[j] == '^' || alternative[j] == '$':
+				misplaced = true
+			}
+		})
+		if misplaced {
+			return "", unsupportedError("pattern", pattern, "the anchors of regexp are only supported at the start and the end of the alternatives")
+		}
+
+		if anchoredEnd {
+			alternative = alternative[:len(alternative)-1]
+		} else {
+			alternative = alternative + ".*"
+		}
+		if anchoredStart {
+			alternative = alternative[1:]
+		} else {
+			alternative = ".*" + alternative
+		}
+		alternatives[i] = translateRegexpEscapes(alternative)
+	}
+	return strings.Join(alternatives, "|"), nil
+}
+
+// handleSelectWhereRegexpExpr translates regexp and rlike
+func handleSelectWhereRegexpExpr(colNameStr string, comparisonExpr *sqlparser.ComparisonExpr, opts *Options) (query, error) {
+	patternVal, err := buildValue(comparisonExpr.Right)
+	if err != nil {
+		return nil, err
+	}
+	pattern, ok := patternVal.(string)
+	if !ok {
+		return nil, valueError("pattern", sqlparser.String(comparisonExpr.Right), "the pattern of regexp must be a string")
+	}
+
+	regexpPattern, err := buildRegexpPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &regexpQuery{
+		field:   colNameStr,
+		pattern: regexpPattern,
+		flags:   opts.RegexpFlags,
+	}, nil
+}
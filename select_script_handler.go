@@ -0,0 +1,333 @@
+package elasticsql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// the scalar functions of sql which can be computed by painless
+// the other functions in select are aggregate functions
+var scriptFuncMap = map[string]string{
+	"abs":   "Math.abs",
+	"ceil":  "Math.ceil",
+	"floor": "Math.floor",
+	"round": "Math.round",
+	"sqrt":  "Math.sqrt",
+	"pow":   "Math.pow",
+	"power": "Math.pow",
+	"exp":   "Math.exp",
+	"ln":    "Math.log",
+	"log10": "Math.log10",
+	"lower": "toLowerCase",
+	"upper": "toUpperCase",
+	// concat and length are built by buildFunc
+	"concat": "",
+	"length": "",
+}
+
+// comparison operators of sql to painless
+var painlessOperatorMap = map[string]string{
+	"=":  "==",
+	"!=": "!=",
+	">":  ">",
+	">=": ">=",
+	"<":  "<",
+	"<=": "<=",
+}
+
+// the operators after swapping the two sides of the comparison
+var flippedOperatorMap = map[string]string{
+	"=":  "=",
+	"!=": "!=",
+	">":  "<",
+	">=": "<=",
+	"<":  ">",
+	"<=": ">=",
+}
+
+func isScriptFunc(funcExpr *sqlparser.FuncExpr) bool {
+	_, ok := scriptFuncMap[funcExpr.Name.Lowered()]
+	return ok
+}
+
+// scriptBuilder translates sql expressions to painless
+// the literals are passed as params, so the compiled script can be reused
+type scriptBuilder struct {
+	params msi
+	// field returns how the script reads the column, the doc values by default
+	field     func(col *sqlparser.ColName) string
+	sourceKey string
+	// columns are the columns read by the script, in the order of the first read
+	columns []*sqlparser.ColName
+}
+
+func newScriptBuilder(opts *Options) *scriptBuilder {
+	return &scriptBuilder{params: make(msi), field: docValueField, sourceKey: opts.scriptSourceKey()}
+}
+
+func docValueField(col *sqlparser.ColName) string {
+	return "doc[" + painlessString(columnName(col)) + "].value"
+}
+
+// columnName is the field of the column in the doc values, where a.b and `a.b` are the same
+func columnName(col *sqlparser.ColName) string {
+	return strings.Replace(sqlparser.String(col), "`", "", -1)
+}
+
+// painlessString quotes s as a string literal of painless,
+// the field names are from the sql and can not become the code of the script
+func painlessString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// script returns the script node of the source built by the builder
+func (b *scriptBuilder) script(source string) *script {
+	return &script{source: source, params: b.params, sourceKey: b.sourceKey}
+}
+
+func (b *scriptBuilder) build(expr sqlparser.Expr) (string, error) {
+	switch e := expr.(type) {
+	case *sqlparser.ColName:
+		b.addColumn(e)
+		return b.field(e), nil
+	case *sqlparser.ParenExpr:
+		inner, err := b.build(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "(" + inner + ")", nil
+	case *sqlparser.BinaryExpr:
+		return b.buildBinary(e)
+	case *sqlparser.UnaryExpr:
+		if e.Operator != sqlparser.UMinusStr && e.Operator != sqlparser.UPlusStr {
+			return "", unsupportedError("operator", e.Operator, "unsupported operator in script")
+		}
+		inner, err := b.build(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return e.Operator + inner, nil
+	case *sqlparser.FuncExpr:
+		return b.buildFunc(e)
+	}
+
+	val, err := buildValue(expr)
+	if err != nil {
+		return "", unsupportedError("expression", sqlparser.String(expr), "unsupported expression in script")
+	}
+	return b.param(val), nil
+}
+
+func (b *scriptBuilder) addColumn(col *sqlparser.ColName) {
+	for _, c := range b.columns {
+		if sqlparser.String(c) == sqlparser.String(col) {
+			return
+		}
+	}
+	b.columns = append(b.columns, col)
+}
+
+// param adds val to the params and returns the variable of it
+func (b *scriptBuilder) param(val interface{}) string {
+	name := fmt.Sprintf("p%d", len(b.params))
+	b.params[name] = val
+	return "params." + name
+}
+
+func (b *scriptBuilder) buildBinary(e *sqlparser.BinaryExpr) (string, error) {
+	switch e.Operator {
+	case sqlparser.PlusStr, sqlparser.MinusStr, sqlparser.MultStr, sqlparser.DivStr, sqlparser.ModStr:
+	default:
+		return "", unsupportedError("operator", e.Operator, "unsupported operator in script")
+	}
+	left, err := b.build(e.Left)
+	if err != nil {
+		return "", err
+	}
+	right, err := b.build(e.Right)
+	if err != nil {
+		return "", err
+	}
+	// / of sql always returns a decimal, while painless divides integers as integers
+	if e.Operator == sqlparser.DivStr {
+		right = "(double) " + right
+	}
+	return left + " " + e.Operator + " " + right, nil
+}
+
+func (b *scriptBuilder) buildFunc(e *sqlparser.FuncExpr) (string, error) {
+	method, ok := scriptFuncMap[e.Name.Lowered()]
+	if !ok {
+		return "", unsupportedError("function", sqlparser.String(e), "unsupported function in script")
+	}
+
+	var args []string
+	for _, arg := range e.Exprs {
+		aliasedExpr, ok := arg.(*sqlparser.AliasedExpr)
+		if !ok {
+			return "", unsupportedError("param", sqlparser.String(arg), "unsupported star expression in function")
+		}
+		argStr, err := b.build(aliasedExpr.Expr)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, argStr)
+	}
+
+	switch e.Name.Lowered() {
+	case "concat":
+		// "" + makes sure the values are concatenated as strings
+		return `"" + ` + strings.Join(args, " + "), nil
+	case "length", "lower", "upper":
+		if len(args) != 1 {
+			return "", unsupportedError("function", sqlparser.String(e), "the function needs exactly one param")
+		}
+		if e.Name.Lowered() == "length" {
+			return args[0] + ".length()", nil
+		}
+		return args[0] + "." + method + "()", nil
+	case "round":
+		// Math.round has no decimal places
+		if len(args) == 2 {
+			return "Math.round(" + args[0] + " * Math.pow(10, " + args[1] + ")) / Math.pow(10, " + args[1] + ")", nil
+		}
+	}
+	return method + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+// buildScriptFields translates the computed select expressions to script fields,
+// the alias or the expression itself is the name of the field
+func buildScriptFields(selectExprs sqlparser.SelectExprs, opts *Options) (msi, error) {
+	var scriptFields = make(msi)
+	for _, v := range selectExprs {
+		expr, ok := v.(*sqlparser.AliasedExpr)
+		if !ok || !isScriptExpr(expr.Expr) {
+			continue
+		}
+		if err := opts.checkPainless(sqlparser.String(expr)); err != nil {
+			return nil, err
+		}
+		b := newScriptBuilder(opts)
+		source, err := b.build(expr.Expr)
+		if err != nil {
+			return nil, err
+		}
+		scriptFields[buildScriptFieldName(expr)] = msi{"script": b.script(source)}
+	}
+	return scriptFields, nil
+}
+
+// the select expressions which are neither columns nor aggregate functions are computed by scripts
+func isScriptExpr(expr sqlparser.Expr) bool {
+	switch e := expr.(type) {
+	case *sqlparser.ColName:
+		return false
+	case *sqlparser.FuncExpr:
+		return isScriptFunc(e)
+	}
+	return true
+}
+
+func buildScriptFieldName(expr *sqlparser.AliasedExpr) string {
+	if !expr.As.IsEmpty() {
+		return expr.As.String()
+	}
+	return strings.Replace(sqlparser.String(expr.Expr), "`", "", -1)
+}
+
+// normalizeComparisonExpr turns 1 < a into a > 1, so the column is always on the left,
+// and removes the parens around the values, eg. a = (1) is a = 1
+func normalizeComparisonExpr(comparisonExpr *sqlparser.ComparisonExpr) *sqlparser.ComparisonExpr {
+	left, right := unwrapValueParens(comparisonExpr.Left), unwrapValueParens(comparisonExpr.Right)
+	if flipped, ok := flippedOperatorMap[comparisonExpr.Operator]; ok && isValueExpr(left) && !isValueExpr(right) {
+		return &sqlparser.ComparisonExpr{
+			Operator: flipped,
+			Left:     right,
+			Right:    left,
+		}
+	}
+	return &sqlparser.ComparisonExpr{
+		Operator: comparisonExpr.Operator,
+		Left:     left,
+		Right:    right,
+		Escape:   comparisonExpr.Escape,
+	}
+}
+
+// unwrapValueParens returns the value in the parens, eg. -1 of ((-1)), the other expressions are returned as is
+func unwrapValueParens(expr sqlparser.Expr) sqlparser.Expr {
+	paren, ok := expr.(*sqlparser.ParenExpr)
+	if !ok {
+		return expr
+	}
+	if inner := unwrapValueParens(paren.Expr); isValueExpr(inner) {
+		return inner
+	}
+	return expr
+}
+
+func isValueExpr(expr sqlparser.Expr) bool {
+	_, err := buildValue(expr)
+	return err == nil
+}
+
+// isScriptComparison checks whether the comparison is between the computed values,
+// eg. a > b, a + 1 = b, abs(a) > 1, which can only be done by a script query
+func isScriptComparison(comparisonExpr *sqlparser.ComparisonExpr) bool {
+	if _, ok := painlessOperatorMap[comparisonExpr.Operator]; !ok {
+		return false
+	}
+	if _, ok := comparisonExpr.Left.(*sqlparser.ColName); !ok {
+		return true
+	}
+	switch right := comparisonExpr.Right.(type) {
+	case *sqlparser.ColName:
+		// a = missing is the missing check
+		return strings.ToLower(sqlparser.String(right)) != "missing"
+	case *sqlparser.BinaryExpr, *sqlparser.ParenExpr:
+		return true
+	case *sqlparser.FuncExpr:
+		return isScriptFunc(right)
+	}
+	return false
+}
+
+// the comparison without any column is always true or false, eg. 1 = 1
+func hasColName(expr sqlparser.Expr) bool {
+	var found = false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if _, ok := node.(*sqlparser.ColName); ok {
+			found = true
+		}
+		return !found, nil
+	}, expr)
+	return found
+}
+
+func handleSelectWhereScriptExpr(comparisonExpr *sqlparser.ComparisonExpr, opts *Options) (query, error) {
+	if !hasColName(comparisonExpr) {
+		return nil, unsupportedError("expression", sqlparser.String(comparisonExpr), "comparison without column is not supported")
+	}
+	if err := opts.checkPainless(sqlparser.String(comparisonExpr)); err != nil {
+		return nil, err
+	}
+
+	b := newScriptBuilder(opts)
+	left, err := b.build(comparisonExpr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.build(comparisonExpr.Right)
+	if err != nil {
+		return nil, err
+	}
+	// .value throws on the documents without the field, they never match the comparison
+	var conditions []string
+	for _, col := range b.columns {
+		conditions = append(conditions, "doc["+painlessString(columnName(col))+"].size() != 0")
+	}
+	conditions = append(conditions, left+" "+painlessOperatorMap[comparisonExpr.Operator]+" "+right)
+	return &scriptQuery{script: b.script(strings.Join(conditions, " && "))}, nil
+}
@@ -1,7 +1,10 @@
 package elasticsql
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"encoding/json"
 
@@ -17,66 +20,139 @@ var badSQLList = []string{
 
 var unsupportedCaseList = []string{
 	"insert into a values(1,2)",
-	"update a set id = 1",
-	"delete from a where id=1",
-	"select * from ak where NOT(id=1)",
 	"select * from ak where 1 = 1",
 	"select * from a,b",
-	"select * from a where 1=a",
-	"select * from a where id is null",
+	"select * from a where 1 is null",
 	"select * from a group by sqrt(id)",
 	"select * from aaa where  a= 1 and multi_match(zz=1, query='this is a test', fields=(title,title.origin), type=phrase)",
 	"select * from aaa where zz(k=2)",
+	"select * from aaa where a like 1",
+	"select count(*) from a having count(*) > 1",
+	"select count(*) from a group by b having stats(c) > 1",
+	"select count(*) from a group by b having count(*) > 'x'",
+	"select count(*) from a group by b having c > 1",
+	"select * from aaa where a <=> 1",
+	"select * from aaa where a regexp 1",
+	"select * from aaa where a like 'x' escape 'ab'",
+	"select count(*) from a group by range(age, 20, 30) order by count(*) desc",
+	"select count(*) from a group by b order by c",
+	"select count(*) from a group by b order by stats(c)",
+	"select count(*) from a group by b limit 'x'",
+	"select abs(x), count(*) from orders",
+	"select a & b from orders",
+	"select lower(a, b) from orders",
+	"select * from a where 1 + 1 = 2",
+	"select * from a where a > b & c",
 }
 
 var selectCaseMap = map[string]string{
 	//"select count(*), occupy from callcenter,bbb where id>0 and a=0 and process_id in (1, 2) and a.t = 1 and (b.c=2 or b.d=1) order by aaa desc, ddd asc  limit 1,2",
-	"select occupy from ark where process_id= 1":                                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where (process_id= 1)":                                                           `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where ((process_id= 1))":                                                         `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where (process_id = 1 and status=1)":                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : "1"}}},{"match_phrase" : {"status" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where process_id > 1":                                                            `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"gt" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where process_id < 1":                                                            `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"lt" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where process_id <= 1":                                                           `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"to" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark_callcenter where process_id >= '1'":                                              `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"from" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark_callcenter where process_id != 1":                                                `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"match_phrase" : {"process_id" : {"query" : "1"}}}]}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark_callcenter where process_id = 0 and status= 1 and channel = 4":                   `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : "0"}}},{"match_phrase" : {"status" : {"query" : "1"}}},{"match_phrase" : {"channel" : {"query" : "4"}}}]}},"from" : 0,"size" : 1}`,
+	"select occupy from ark where process_id= 1":                                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark where (process_id= 1)":                                                           `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark where ((process_id= 1))":                                                         `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark where (process_id = 1 and status=1)":                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : 1}}},{"match_phrase" : {"status" : {"query" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark where process_id > 1":                                                            `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"gt" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark where process_id < 1":                                                            `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"lt" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark where process_id <= 1":                                                           `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"to" : 1}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark_callcenter where process_id >= '1'":                                              `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"from" : "1"}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark_callcenter where process_id != 1":                                                `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"match_phrase" : {"process_id" : {"query" : 1}}}]}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select occupy from ark_callcenter where process_id = 0 and status= 1 and channel = 4":                   `{"query" : {"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : 0}}},{"match_phrase" : {"status" : {"query" : 1}}},{"match_phrase" : {"channel" : {"query" : 4}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
 	"select * from ark_callcenter where create_time between '2015-01-01 00:00:00' and '2015-01-01 00:00:00'": `{"query" : {"bool" : {"must" : [{"range" : {"create_time" : {"from" : "2015-01-01 00:00:00", "to" : "2015-01-01 00:00:00"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark_callcenter where process_id > 1 and status = 1":                                       `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"gt" : "1"}}},{"match_phrase" : {"status" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark_callcenter where create_time between '2015-01-01T00:00:00+0800' and '2017-01-01T00:00:00+0800' and process_id = 0 and status >= 1 and content = '三个男人' and phone = '15810324322'": `{"query" : {"bool" : {"must" : [{"range" : {"create_time" : {"from" : "2015-01-01T00:00:00+0800", "to" : "2017-01-01T00:00:00+0800"}}},{"match_phrase" : {"process_id" : {"query" : "0"}}},{"range" : {"status" : {"from" : "1"}}},{"match_phrase" : {"content" : {"query" : "三个男人"}}},{"match_phrase" : {"phone" : {"query" : "15810324322"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark_callcenter where id > 1 or process_id = 0":                                      `{"query" : {"bool" : {"should" : [{"range" : {"id" : {"gt" : "1"}}},{"match_phrase" : {"process_id" : {"query" : "0"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark where id > 1 and d = 1 or process_id = 0 and x = 2":                             `{"query" : {"bool" : {"should" : [{"bool" : {"must" : [{"range" : {"id" : {"gt" : "1"}}},{"match_phrase" : {"d" : {"query" : "1"}}}]}},{"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : "0"}}},{"match_phrase" : {"x" : {"query" : "2"}}}]}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark where id > 1 order by id asc, order_id desc":                                    `{"query" : {"bool" : {"must" : [{"range" : {"id" : {"gt" : "1"}}}]}},"from" : 0,"size" : 1,"sort" : [{"id": "asc"},{"order_id": "desc"}]}`,
-	"select * from ark where (id > 1 and d = 1)":                                                       `{"query" : {"bool" : {"must" : [{"range" : {"id" : {"gt" : "1"}}},{"match_phrase" : {"d" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark where (id > 1 and d = 1) or (c=1)":                                              `{"query" : {"bool" : {"should" : [{"bool" : {"must" : [{"range" : {"id" : {"gt" : "1"}}},{"match_phrase" : {"d" : {"query" : "1"}}}]}},{"match_phrase" : {"c" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark where id > 1 or (process_id = 0)":                                               `{"query" : {"bool" : {"should" : [{"range" : {"id" : {"gt" : "1"}}},{"match_phrase" : {"process_id" : {"query" : "0"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark_callcenter where process_id > 1 and status = 1":                                       `{"query" : {"bool" : {"must" : [{"range" : {"process_id" : {"gt" : 1}}},{"match_phrase" : {"status" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark_callcenter where create_time between '2015-01-01T00:00:00+0800' and '2017-01-01T00:00:00+0800' and process_id = 0 and status >= 1 and content = '三个男人' and phone = '15810324322'": `{"query" : {"bool" : {"must" : [{"range" : {"create_time" : {"from" : "2015-01-01T00:00:00+0800", "to" : "2017-01-01T00:00:00+0800"}}},{"match_phrase" : {"process_id" : {"query" : 0}}},{"range" : {"status" : {"from" : 1}}},{"match_phrase" : {"content" : {"query" : "三个男人"}}},{"match_phrase" : {"phone" : {"query" : "15810324322"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark_callcenter where id > 1 or process_id = 0":                                      `{"query" : {"bool" : {"should" : [{"range" : {"id" : {"gt" : 1}}},{"match_phrase" : {"process_id" : {"query" : 0}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark where id > 1 and d = 1 or process_id = 0 and x = 2":                             `{"query" : {"bool" : {"should" : [{"bool" : {"must" : [{"range" : {"id" : {"gt" : 1}}},{"match_phrase" : {"d" : {"query" : 1}}}]}},{"bool" : {"must" : [{"match_phrase" : {"process_id" : {"query" : 0}}},{"match_phrase" : {"x" : {"query" : 2}}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark where id > 1 order by id asc, order_id desc":                                    `{"query" : {"bool" : {"must" : [{"range" : {"id" : {"gt" : 1}}}]}},"from" : 0,"size" : 1,"sort" : [{"id": "asc"},{"order_id": "desc"}]}`,
+	"select * from ark where (id > 1 and d = 1)":                                                       `{"query" : {"bool" : {"must" : [{"range" : {"id" : {"gt" : 1}}},{"match_phrase" : {"d" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark where (id > 1 and d = 1) or (c=1)":                                              `{"query" : {"bool" : {"should" : [{"bool" : {"must" : [{"range" : {"id" : {"gt" : 1}}},{"match_phrase" : {"d" : {"query" : 1}}}]}},{"match_phrase" : {"c" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark where id > 1 or (process_id = 0)":                                               `{"query" : {"bool" : {"should" : [{"range" : {"id" : {"gt" : 1}}},{"match_phrase" : {"process_id" : {"query" : 0}}}]}},"from" : 0,"size" : 1}`,
 	"select * from ark where id in (1,2,3,4)":                                                          `{"query" : {"bool" : {"must" : [{"terms" : {"id" : [1, 2, 3, 4]}}]}},"from" : 0,"size" : 1}`,
 	"select * from ark where id in ('232', '323') and content = 'aaaa'":                                `{"query" : {"bool" : {"must" : [{"terms" : {"id" : ["232", "323"]}},{"match_phrase" : {"content" : {"query" : "aaaa"}}}]}},"from" : 0,"size" : 1}`,
-	"select occupy from ark where create_time between '2015-01-01 00:00:00' and '2014-02-02 00:00:00'": `{"query" : {"bool" : {"must" : [{"range" : {"create_time" : {"from" : "2015-01-01 00:00:00", "to" : "2014-02-02 00:00:00"}}}]}},"from" : 0,"size" : 1}`,
-	"select x from ark where a like '%a%'":                                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : "a"}}}]}},"from" : 0,"size" : 1}`,
+	"select occupy from ark where create_time between '2015-01-01 00:00:00' and '2014-02-02 00:00:00'": `{"query" : {"bool" : {"must" : [{"range" : {"create_time" : {"from" : "2015-01-01 00:00:00", "to" : "2014-02-02 00:00:00"}}}]}},"from" : 0,"size" : 1,"_source" : ["occupy"]}`,
+	"select x from ark where a like '%a%'":                                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : "a"}}}]}},"from" : 0,"size" : 1,"_source" : ["x"]}`,
+	"select ark.* from ark":                                                                            `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1}`,
+	"select *, id from ark":                                                                            `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1}`,
+	"select id, user.*, `desc` from ark":                                                               `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : ["id","user.*","desc"]}`,
+	"select user.name, id from ark limit 5":                                                            `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 5,"_source" : ["user.name","id"]}`,
 	"select count(*) from ark group by date_histogram(field='create_time', value='1h')":                `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=create_time,value=1h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"create_time","format":"yyyy-MM-dd HH:mm:ss","interval":"1h"}}}}`,
 	"select * from ark group by date_histogram(field='create_time', value='1h')":                       `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=create_time,value=1h)":{"date_histogram":{"field":"create_time","format":"yyyy-MM-dd HH:mm:ss","interval":"1h"}}}}`,
 	"select * from ark group by date_histogram(field='create_time', value='4h')":                       `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=create_time,value=4h)":{"date_histogram":{"field":"create_time","format":"yyyy-MM-dd HH:mm:ss","interval":"4h"}}}}`,
 	"select * from ark group by date_histogram(field='create_time', value='1h'), id":                   `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=create_time,value=1h)":{"aggregations":{"id":{"terms":{"field":"id","size":0}}},"date_histogram":{"field":"create_time","format":"yyyy-MM-dd HH:mm:ss","interval":"1h"}}}}`,
 	//	"select * from ark where a like group_concat('%', 'abc', '%')":                                                                                                                                       `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : "abc"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from `order`.abcd where `by` = 1":                                `{"query" : {"bool" : {"must" : [{"match_phrase" : {"by" : {"query" : "1"}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark where id not like '%aaa%'":                              `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : {"match_phrase" : {"id" : {"query" : "aaa"}}}}}]}},"from" : 0,"size" : 1}`,
-	"select * from ark where id not in (1,2,3)":                                `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : {"terms" : {"id" : [1, 2, 3]}}}}]}},"from" : 0,"size" : 1}`,
-	"select * from abc limit 10,10":                                            `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 10,"size" : 10}`,
-	"select * from abc limit 10":                                               `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 10}`,
-	"select count(*), id from ark group by id":                                 `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"id":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"id","size":200}}}}`,
-	"SELECT COUNT(distinct age) FROM bank GROUP BY range(age, 20,25,30,35,40)": `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"range(age,20,25,30,35,40)":{"aggregations":{"COUNT(age)":{"cardinality":{"field":"age"}}},"range":{"field":"age","ranges":[{"from":"20","to":"25"},{"from":"25","to":"30"},{"from":"30","to":"35"},{"from":"35","to":"40"}]}}}}`,
-	"select * from a where id != missing":                                      `{"query" : {"bool" : {"must" : [{"bool" : {"must" : [{"exists":{"field":"id"}}]}}]}},"from" : 0,"size" : 1} `,
-	"select * from a where id = missing":                                       `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"exists":{"field":"id"}}]}}]}},"from" : 0,"size" : 1} `,
-	"select count(*) from a":                                                   `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"COUNT(*)":{"value_count":{"field":"_index"}}}}`,
+	"select * from `order`.abcd where `by` = 1":                                              `{"query" : {"bool" : {"must" : [{"match_phrase" : {"by" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark where id not like '%aaa%'":                                            `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"match_phrase" : {"id" : {"query" : "aaa"}}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from ark where id not in (1,2,3)":                                              `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"terms" : {"id" : [1, 2, 3]}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from abc limit 10,10":                                                          `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 10,"size" : 10}`,
+	"select * from abc limit 10":                                                             `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 10}`,
+	"select count(*), id from ark group by id":                                               `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"id":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"id","size":200}}}}`,
+	"SELECT COUNT(distinct age) FROM bank GROUP BY range(age, 20,25,30,35,40)":               `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"range(age,20,25,30,35,40)":{"aggregations":{"COUNT(age)":{"cardinality":{"field":"age"}}},"range":{"field":"age","ranges":[{"from":20,"to":25},{"from":25,"to":30},{"from":30,"to":35},{"from":35,"to":40}]}}}}`,
+	"select * from a where id != missing":                                                    `{"query" : {"bool" : {"must" : [{"bool" : {"must" : [{"exists":{"field":"id"}}]}}]}},"from" : 0,"size" : 1} `,
+	"select * from a where id = missing":                                                     `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"exists":{"field":"id"}}]}}]}},"from" : 0,"size" : 1} `,
+	"select * from a where id is null":                                                       `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"exists" : {"field" : "id"}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where id is not null":                                                   `{"query" : {"bool" : {"must" : [{"exists" : {"field" : "id"}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where id is not null and (a = 1 or b is null)":                          `{"query" : {"bool" : {"must" : [{"exists" : {"field" : "id"}},{"bool" : {"should" : [{"match_phrase" : {"a" : {"query" : 1}}},{"bool" : {"must_not" : [{"exists" : {"field" : "b"}}]}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where deleted is true":                                                  `{"query" : {"bool" : {"must" : [{"term" : {"deleted" : true}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where deleted is false or deleted is not true":                          `{"query" : {"bool" : {"should" : [{"term" : {"deleted" : false}},{"bool" : {"must_not" : [{"term" : {"deleted" : true}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where deleted is not false":                                             `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"term" : {"deleted" : false}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from ak where NOT(id=1)":                                                       `{"query" : {"bool" : {"must_not" : [{"match_phrase" : {"id" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ak where not (status = 1 or status = 2)":                                  `{"query" : {"bool" : {"must_not" : [{"bool" : {"should" : [{"match_phrase" : {"status" : {"query" : 1}}},{"match_phrase" : {"status" : {"query" : 2}}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from ak where not not (id = 1)":                                                `{"query" : {"bool" : {"must" : [{"match_phrase" : {"id" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ak where a = 1 and not (not (b = 2 and c = 3))":                           `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}},{"match_phrase" : {"b" : {"query" : 2}}},{"match_phrase" : {"c" : {"query" : 3}}}]}},"from" : 0,"size" : 1}`,
+	"select * from ak where a = 1 and not (b = 2 and c = 3)":                                 `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}},{"bool" : {"must_not" : [{"bool" : {"must" : [{"match_phrase" : {"b" : {"query" : 2}}},{"match_phrase" : {"c" : {"query" : 3}}}]}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from ak where a = 1 or not (b = 2 or not c is null)":                           `{"query" : {"bool" : {"should" : [{"match_phrase" : {"a" : {"query" : 1}}},{"bool" : {"must_not" : [{"bool" : {"should" : [{"match_phrase" : {"b" : {"query" : 2}}},{"bool" : {"must_not" : [{"bool" : {"must_not" : [{"exists" : {"field" : "c"}}]}}]}}]}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name = 'a\"b\\\\c' and title = 'it''s'":                           `{"query" : {"bool" : {"must" : [{"match_phrase" : {"name" : {"query" : "a\"b\\c"}}},{"match_phrase" : {"title" : {"query" : "it's"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name in ('x\"}, {\"y', 'z')":                                      `{"query" : {"bool" : {"must" : [{"terms" : {"name" : ["x\"}, {\"y", "z"]}}]}},"from" : 0,"size" : 1}`,
+	"select * from aaa where multi_match(query='say \"hi\"', fields=(title))":                `{"query" : {"multi_match" : {"query" : "say \"hi\"", "fields" : ["title"]}},"from" : 0,"size" : 1}`,
+	"select * from a where a = 1.5 and b > -2 and c < -0.25 and d = 'x'":                     `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1.5}}},{"range" : {"b" : {"gt" : -2}}},{"range" : {"c" : {"lt" : -0.25}}},{"match_phrase" : {"d" : {"query" : "x"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where a = true and b != false and c = null":                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : true}}},{"bool" : {"must_not" : [{"match_phrase" : {"b" : {"query" : false}}}]}},{"match_phrase" : {"c" : {"query" : null}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where a = 0x1F and b = x'4D7953514C' and c = b'101'":                    `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 31}}},{"match_phrase" : {"b" : {"query" : "MySQL"}}},{"match_phrase" : {"c" : {"query" : 5}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where id in (1, 2.5, 'x', true, null)":                                  `{"query" : {"bool" : {"must" : [{"terms" : {"id" : [1, 2.5, "x", true, null]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where id between 10 and 20.5":                                           `{"query" : {"bool" : {"must" : [{"range" : {"id" : {"from" : 10, "to" : 20.5}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'abc%'":                                                 `{"query" : {"bool" : {"must" : [{"prefix" : {"name" : {"value" : "abc"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name not like 'abc%'":                                             `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"prefix" : {"name" : {"value" : "abc"}}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like '%abc'":                                                 `{"query" : {"bool" : {"must" : [{"wildcard" : {"name" : {"value" : "*abc"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'a_c%d'":                                                `{"query" : {"bool" : {"must" : [{"wildcard" : {"name" : {"value" : "a?c*d"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'a*b?c_%'":                                              `{"query" : {"bool" : {"must" : [{"wildcard" : {"name" : {"value" : "a\\*b\\?c?*"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'a*b?c%'":                                               `{"query" : {"bool" : {"must" : [{"prefix" : {"name" : {"value" : "a*b?c"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'a|%b%' escape '|'":                                     `{"query" : {"bool" : {"must" : [{"prefix" : {"name" : {"value" : "a%b"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'a\\\\_b'":                                              `{"query" : {"bool" : {"must" : [{"match_phrase" : {"name" : {"query" : "a_b"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like 'abc'":                                                  `{"query" : {"bool" : {"must" : [{"match_phrase" : {"name" : {"query" : "abc"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name like '%'":                                                    `{"query" : {"bool" : {"must" : [{"exists" : {"field" : "name"}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name regexp 'ab+c'":                                               `{"query" : {"bool" : {"must" : [{"regexp" : {"name" : {"value" : ".*ab+c.*", "flags" : "NONE"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name rlike '^ab|c$'":                                              `{"query" : {"bool" : {"must" : [{"regexp" : {"name" : {"value" : "ab.*|.*c", "flags" : "NONE"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name not regexp '^(a|b)[0-9]$'":                                   `{"query" : {"bool" : {"must" : [{"bool" : {"must_not" : [{"regexp" : {"name" : {"value" : "(a|b)[0-9]", "flags" : "NONE"}}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where name regexp '^\\\\d+[\\\\w]\"$'":                                  `{"query" : {"bool" : {"must" : [{"regexp" : {"name" : {"value" : "[0-9]+[a-zA-Z0-9_]\\\"", "flags" : "NONE"}}}]}},"from" : 0,"size" : 1}`,
+	"select region, count(*) from a group by region having count(*) > 10 and avg(price) < 5": `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"AVG(price)":{"avg":{"field":"price"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count","p1":"AVG(price)"},"script":"params.p0 > 10 && params.p1 < 5"}}},"terms":{"field":"region","size":200}}}}`,
+	"select region, avg(user.age) from a group by region having avg(user.age) > 20":          `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"AVG(user_age)":{"avg":{"field":"user.age"}},"having":{"bucket_selector":{"buckets_path":{"p0":"AVG(user_age)"},"script":"params.p0 > 20"}}},"terms":{"field":"region","size":200}}}}`,
+	"select avg(price) from a group by region, city having (max(price) - avg(price) >= 2.5 or count(*) = 1) and not avg(price) != 0": `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"city":{"aggregations":{"AVG(price)":{"avg":{"field":"price"}},"MAX(price)":{"max":{"field":"price"}},"having":{"bucket_selector":{"buckets_path":{"p0":"MAX(price)","p1":"AVG(price)","p2":"_count"},"script":"(params.p0 - params.p1 >= 2.5 || params.p2 == 1) && !(params.p1 != 0)"}}},"terms":{"field":"city","size":0}}},"terms":{"field":"region","size":200}}}}`,
+	"select count(*) from a": `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"COUNT(*)":{"value_count":{"field":"_index"}}}}`,
 	"SELECT online FROM online GROUP BY date_range(field='insert_time' , format='yyyy-MM-dd', '2014-08-18','2014-08-17','now-8d','now-7d','now-6d','now')": `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_range(field=insert_time,format=yyyy-MM-dd,2014-08-18,2014-08-17,now-8d,now-7d,now-6d,now)":{"date_range":{"field":"insert_time","format":"yyyy-MM-dd","ranges":[{"from":"2014-08-18","to":"2014-08-17"},{"from":"2014-08-17","to":"now-8d"},{"from":"now-8d","to":"now-7d"},{"from":"now-7d","to":"now-6d"},{"from":"now-6d","to":"now"}]}}}}`,
-	"select count(id), sum(age) from a group by id":                                                                           `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"id":{"aggregations":{"COUNT(id)":{"value_count":{"field":"id"}},"SUM(age)":{"sum":{"field":"age"}}},"terms":{"field":"id","size":200}}}}`,
-	"select * from a order by `order`.abc":                                                                                    `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"sort" : [{"order.abc": "asc"}]}`,
-	"select * from aaa where multi_match(query='this is a test', fields=(title,title.origin))":                                `{"query" : {"multi_match" : {"query" : "this is a test", "fields" : ["title","title.origin"]}},"from" : 0,"size" : 1}`,
-	"select * from aaa where  a= 1 and multi_match(query='this is a test', fields=(title,title.origin))":                      `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : "1"}}},{"multi_match" : {"query" : "this is a test", "fields" : ["title","title.origin"]}}]}},"from" : 0,"size" : 1}`,
-	"select * from aaa where  a= 1 and multi_match(query='this is a test', fields=(title,title.origin), type=phrase)":         `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : "1"}}},{"multi_match" : {"query" : "this is a test", "type" : "phrase", "fields" : ["title","title.origin"]}}]}},"from" : 0,"size" : 1}`,
-	"SELECT `id`, `name` FROM `student` WHERE (`name` LIKE '%aaa!_bbb!!ccc%' ESCAPE '!') ORDER BY `updateTime` DESC LIMIT 10": `{"query" : {"bool" : {"must" : [{"match_phrase" : {"name" : {"query" : "aaa_bbb!ccc"}}}]}},"from" : 0,"size" : 10,"sort" : [{"updateTime": "desc"}]}`,
-	"SELECT `id`, `name` FROM `student` WHERE (`name` LIKE '%aaa!_bbb!!ccc%') ORDER BY `updateTime` DESC LIMIT 10":            `{"query" : {"bool" : {"must" : [{"match_phrase" : {"name" : {"query" : "aaa!_bbb!!ccc"}}}]}},"from" : 0,"size" : 10,"sort" : [{"updateTime": "desc"}]}`,
+	"select count(id), sum(age) from a group by id":                                                                                                  `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"id":{"aggregations":{"COUNT(id)":{"value_count":{"field":"id"}},"SUM(age)":{"sum":{"field":"age"}}},"terms":{"field":"id","size":200}}}}`,
+	"select region, count(*) from a group by region order by count(*) desc limit 10":                                                                 `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"region","order":[{"_count":"desc"}],"size":10}}}}`,
+	"select region, avg(price) from a group by region order by avg(price) asc, region desc limit 5, 10":                                              `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"AVG(price)":{"avg":{"field":"price"}},"limit":{"bucket_sort":{"from":5,"size":10}}},"terms":{"field":"region","order":[{"AVG(price)":"asc"},{"_key":"desc"}],"size":15}}}}`,
+	"select region, sum(user.age) from a group by region order by sum(user.age) desc":                                                                `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"SUM(user_age)":{"sum":{"field":"user.age"}}},"terms":{"field":"region","order":[{"SUM(user_age)":"desc"}],"size":200}}}}`,
+	"select count(*) from a group by region, city order by region asc, max(price) desc":                                                              `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"MAX(price)":{"max":{"field":"price"}}},"terms":{"field":"city","order":[{"MAX(price)":"desc"}],"size":0}}},"terms":{"field":"region","order":[{"_key":"asc"}],"size":200}}}}`,
+	"select count(*) from a group by date_histogram(field='ctime', value='1d') order by date_histogram(field='ctime', value='1d') desc limit 7":      `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=ctime,value=1d)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"limit":{"bucket_sort":{"from":0,"size":7}}},"date_histogram":{"field":"ctime","format":"yyyy-MM-dd HH:mm:ss","interval":"1d","order":[{"_key":"desc"}]}}}}`,
+	"select count(*) from a group by range(age, 20, 30) limit 1":                                                                                     `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"range(age,20,30)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"limit":{"bucket_sort":{"from":0,"size":1}}},"range":{"field":"age","ranges":[{"from":20,"to":30}]}}}}`,
+	"select region as r, count(*) as total, avg(price) ap from a group by r having total > 10 and avg(price) > 1 order by total desc, r asc limit 5": `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"r":{"aggregations":{"total":{"value_count":{"field":"_index"}},"ap":{"avg":{"field":"price"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count","p1":"ap"},"script":"params.p0 > 10 && params.p1 > 1"}}},"terms":{"field":"region","order":[{"_count":"desc"},{"_key":"asc"}],"size":5}}}}`,
+	"select date_histogram(field='ctime', value='1d') as day, max(price) as top from a group by day order by day desc":                               `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"day":{"aggregations":{"top":{"max":{"field":"price"}}},"date_histogram":{"field":"ctime","format":"yyyy-MM-dd HH:mm:ss","interval":"1d","order":[{"_key":"desc"}]}}}}`,
+	"select name as n, id from ark order by n desc":                                                                                                  `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : ["name","id"],"sort" : [{"name": "desc"}]}`,
+	"select * from ak where not (1 = id)":                                                                                                            `{"query" : {"bool" : {"must_not" : [{"match_phrase" : {"id" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where 1=a":                                                                                                                      `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where 10 <= a and 'x' != b":                                                                                                     `{"query" : {"bool" : {"must" : [{"range" : {"a" : {"from" : 10}}},{"bool" : {"must_not" : [{"match_phrase" : {"b" : {"query" : "x"}}}]}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where a > b":                                                                                                                    `{"query" : {"bool" : {"must" : [{"script" : {"script" : {"lang" : "painless","source" : "doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value > doc['b'].value"}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where a + 1 = b and c = 1":                                                                                                      `{"query" : {"bool" : {"must" : [{"script" : {"script" : {"lang" : "painless","params" : {"p0" : 1},"source" : "doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value + params.p0 == doc['b'].value"}}},{"match_phrase" : {"c" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`,
+	"select * from a where abs(a - b) >= 0.5 or a = b * 2":                                                                                           `{"query" : {"bool" : {"should" : [{"script" : {"script" : {"lang" : "painless","params" : {"p0" : 0.5},"source" : "doc['a'].size() != 0 && doc['b'].size() != 0 && Math.abs(doc['a'].value - doc['b'].value) >= params.p0"}}},{"script" : {"script" : {"lang" : "painless","params" : {"p0" : 2},"source" : "doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value == doc['b'].value * params.p0"}}}]}},"from" : 0,"size" : 1}`,
+	"select price * qty as total from orders":                                                                                                        `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : false,"script_fields" : {"total" : {"script" : {"lang" : "painless","source" : "doc['price'].value * doc['qty'].value"}}}}`,
+	"select *, abs(x) from orders":                                                                                                                   `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"script_fields" : {"abs(x)" : {"script" : {"lang" : "painless","source" : "Math.abs(doc['x'].value)"}}}}`,
+	"select id, round(price / 3, 2) as p, concat(first, ' ', last) name, upper(`desc`), -a - (b % 2) from orders":                                    `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : ["id"],"script_fields" : {"p" : {"script" : {"lang" : "painless","params" : {"p0" : 3,"p1" : 2},"source" : "Math.round(doc['price'].value / (double) params.p0 * Math.pow(10, params.p1)) / Math.pow(10, params.p1)"}},"name" : {"script" : {"lang" : "painless","params" : {"p0" : " "},"source" : "\"\" + doc['first'].value + params.p0 + doc['last'].value"}},"upper(desc)" : {"script" : {"lang" : "painless","source" : "doc['desc'].value.toUpperCase()"}},"-a - (b % 2)" : {"script" : {"lang" : "painless","params" : {"p0" : 2},"source" : "-doc['a'].value - (doc['b'].value % params.p0)"}}}}`,
+	"select `a'].value + doc['b` * 2 as x from orders":                                                                                               `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : false,"script_fields" : {"x" : {"script" : {"lang" : "painless","params" : {"p0" : 2},"source" : "doc['a\\'].value + doc[\\'b'].value * params.p0"}}}}`,
+	"select * from a order by `order`.abc":                                                                                                           `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"sort" : [{"order.abc": "asc"}]}`,
+	"select * from aaa where multi_match(query='this is a test', fields=(title,title.origin))":                                                       `{"query" : {"multi_match" : {"query" : "this is a test", "fields" : ["title","title.origin"]}},"from" : 0,"size" : 1}`,
+	"select * from aaa where  a= 1 and multi_match(query='this is a test', fields=(title,title.origin))":                                             `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}},{"multi_match" : {"query" : "this is a test", "fields" : ["title","title.origin"]}}]}},"from" : 0,"size" : 1}`,
+	"select * from aaa where  a= 1 and multi_match(query='this is a test', fields=(title,title.origin), type=phrase)":                                `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}},{"multi_match" : {"query" : "this is a test", "type" : "phrase", "fields" : ["title","title.origin"]}}]}},"from" : 0,"size" : 1}`,
+	"SELECT `id`, `name` FROM `student` WHERE (`name` LIKE '%aaa!_bbb!!ccc%' ESCAPE '!') ORDER BY `updateTime` DESC LIMIT 10":                        `{"query" : {"bool" : {"must" : [{"match_phrase" : {"name" : {"query" : "aaa_bbb!ccc"}}}]}},"from" : 0,"size" : 10,"_source" : ["id","name"],"sort" : [{"updateTime": "desc"}]}`,
+	"SELECT `id`, `name` FROM `student` WHERE (`name` LIKE '%aaa!_bbb!!ccc%') ORDER BY `updateTime` DESC LIMIT 10":                                   `{"query" : {"bool" : {"must" : [{"wildcard" : {"name" : {"value" : "*aaa!?bbb!!ccc*"}}}]}},"from" : 0,"size" : 10,"_source" : ["id","name"],"sort" : [{"updateTime": "desc"}]}`,
 }
 
 func TestSupported(t *testing.T) {
@@ -147,3 +223,391 @@ func TestBadSQL(t *testing.T) {
 		}
 	}
 }
+
+var optionsCaseList = []struct {
+	sql  string
+	opts Options
+	dsl  string
+}{
+	{"select * from ark where a = 1 and b != 'x'", Options{Equality: TermEquality}, `{"query" : {"bool" : {"must" : [{"term" : {"a" : 1}},{"bool" : {"must_not" : [{"term" : {"b" : "x"}}]}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where title = 'x' and status = 1 and city != 'bj' and other = 2", Options{FieldTypes: map[string]string{"title": "text", "status": "long", "city": "keyword"}}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"title" : {"query" : "x"}}},{"term" : {"status" : 1}},{"bool" : {"must_not" : [{"term" : {"city" : "bj"}}]}},{"match_phrase" : {"other" : {"query" : 2}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where title = 'x' and other = 2", Options{Equality: TermEquality, FieldTypes: map[string]string{"title": "text"}}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"title" : {"query" : "x"}}},{"term" : {"other" : 2}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where name like '%abc%' and title like '%abc%'", Options{FieldTypes: map[string]string{"name": "keyword"}}, `{"query" : {"bool" : {"must" : [{"wildcard" : {"name" : {"value" : "*abc*"}}},{"match_phrase" : {"title" : {"query" : "abc"}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where name like 'a\\_b%' and title like '10\\%' and body like '\\\\\\_' # it's \\_", Options{}, `{"query" : {"bool" : {"must" : [{"prefix" : {"name" : {"value" : "a_b"}}},{"match_phrase" : {"title" : {"query" : "10%"}}},{"wildcard" : {"body" : {"value" : "\\\\?"}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where name like 'abc'", Options{Equality: TermEquality}, `{"query" : {"bool" : {"must" : [{"term" : {"name" : "abc"}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where name regexp 'a@b'", Options{RegexpFlags: "ALL"}, `{"query" : {"bool" : {"must" : [{"regexp" : {"name" : {"value" : ".*a@b.*", "flags" : "ALL"}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where name regexp 'a\\\\$|b\\\\\\\\$|[$^]c'", Options{}, `{"query" : {"bool" : {"must" : [{"regexp" : {"name" : {"value" : ".*a\\$.*|.*b\\\\|.*[$^]c.*", "flags" : "NONE"}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where a = (1) and b > ((-1.5)) and (2) < c", Options{}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}},{"range" : {"b" : {"gt" : -1.5}}},{"range" : {"c" : {"gt" : 2}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where a = 1", Options{DefaultSize: 20}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}}]}},"from" : 0,"size" : 20}`},
+	{"select * from ark limit 5", Options{DefaultSize: 20}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 5}`},
+	{"select count(*), id from ark group by id", Options{TermsSize: 10}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"id":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"id","size":10}}}}`},
+	{"select * from ark group by date_histogram(field='create_time')", Options{DateFormat: "yyyy-MM-dd", DateInterval: "1d"}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=create_time)":{"date_histogram":{"field":"create_time","format":"yyyy-MM-dd","interval":"1d"}}}}`},
+	{"select * from ark group by date_histogram(field='create_time', value='1h', format='HH')", Options{DateFormat: "yyyy-MM-dd", DateInterval: "1d"}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"date_histogram(field=create_time,value=1h,format=HH)":{"date_histogram":{"field":"create_time","format":"HH","interval":"1h"}}}}`},
+	{"select count(*) from ark group by region, date_histogram(field='ctime', value='1d') having count(*) > 1 order by region desc limit 100", Options{CompositeAggregation: true}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"composite":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 1"}}},"composite":{"size":100,"sources":[{"region":{"terms":{"field":"region","order":"desc"}}},{"date_histogram(field=ctime,value=1d)":{"date_histogram":{"field":"ctime","format":"yyyy-MM-dd HH:mm:ss","interval":"1d"}}}]}}}}`},
+	{"select count(*) from ark group by region", Options{CompositeAggregation: true, TermsSize: 10}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"composite":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"composite":{"size":10,"sources":[{"region":{"terms":{"field":"region"}}}]}}}}`},
+	{"select id, name from ark", Options{DocValueFields: true}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : ["id","name"],"docvalue_fields" : ["id","name"]}`},
+	{"select id, name from ark", Options{StoredFields: true}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1,"_source" : ["id","name"],"stored_fields" : ["id","name"]}`},
+	{"select * from ark", Options{DocValueFields: true, StoredFields: true}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 1}`},
+	{"select region as r, count(*) total from ark group by r order by r", Options{CompositeAggregation: true}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"composite":{"aggregations":{"total":{"value_count":{"field":"_index"}}},"composite":{"size":200,"sources":[{"r":{"terms":{"field":"region","order":"asc"}}}]}}}}`},
+	{"select * from ark where id = ? and name = ? and x in ::ids and y in (?) and z between ? and ? and t > :t limit ?, ?", Options{Params: []interface{}{int64(1), `a"b'c`, []string{"p", "q"}, 1.5, -2, 10, 20}, NamedParams: map[string]interface{}{"ids": []int{1, 2}, "t": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"id" : {"query" : 1}}},{"match_phrase" : {"name" : {"query" : "a\"b'c"}}},{"terms" : {"x" : [1,2]}},{"terms" : {"y" : ["p","q"]}},{"range" : {"z" : {"from" : 1.5,"to" : -2}}},{"range" : {"t" : {"gt" : "2020-01-02T03:04:05Z"}}}]}},"from" : 10,"size" : 20}`},
+	{"select * from ark where name like ? and ok = :ok", Options{Params: []interface{}{"ab%"}, NamedParams: map[string]interface{}{"ok": true}}, `{"query" : {"bool" : {"must" : [{"prefix" : {"name" : {"value" : "ab"}}},{"match_phrase" : {"ok" : {"query" : true}}}]}},"from" : 0,"size" : 1}`},
+	{"select * from ark where a = ?", Options{Params: []interface{}{1}, NamedParams: map[string]interface{}{"v1": 2}}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : 1}}}]}},"from" : 0,"size" : 1}`},
+	{"select region, count(*) from ark group by region having count(*) > ?", Options{Params: []interface{}{10}}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 10"}}},"terms":{"field":"region","size":200}}}}`},
+	{"select * from ark where a = '?' -- ?\n and b = ? /* :v1 */ and c = 'it''s ?' and `d?` = :d", Options{Params: []interface{}{1}, NamedParams: map[string]interface{}{"d": 2}}, `{"query" : {"bool" : {"must" : [{"match_phrase" : {"a" : {"query" : "?"}}},{"match_phrase" : {"b" : {"query" : 1}}},{"match_phrase" : {"c" : {"query" : "it's ?"}}},{"match_phrase" : {"d?" : {"query" : 2}}}]}},"from" : 0,"size" : 1}`},
+	{"select count(*) from ark", Options{CompositeAggregation: true}, `{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"COUNT(*)":{"value_count":{"field":"_index"}}}}`},
+}
+
+func TestConvertWithOptions(t *testing.T) {
+	for _, c := range optionsCaseList {
+		var dslMap map[string]interface{}
+		err := json.Unmarshal([]byte(c.dsl), &dslMap)
+		if err != nil {
+			t.Error("test case json unmarshal err!", c.sql)
+		}
+
+		for _, convert := range []func(string, Options) (string, string, error){ConvertWithOptions, ConvertPrettyWithOptions} {
+			dsl, _, err := convert(c.sql, c.opts)
+			if err != nil {
+				t.Error("convert with options failed", c.sql, err)
+				continue
+			}
+
+			var dslConvertedMap map[string]interface{}
+			err = json.Unmarshal([]byte(dsl), &dslConvertedMap)
+			if err != nil {
+				t.Error("the generated dsl json unmarshal error!", c.sql)
+			}
+
+			if !reflect.DeepEqual(dslMap, dslConvertedMap) {
+				t.Error("the generated dsl is not equal to expected", c.sql, dsl)
+			}
+		}
+	}
+}
+
+var mappingCaseMap = map[string]map[string]string{
+	// typeless mapping of 7.x and later
+	`{"ark":{"mappings":{"properties":{"title":{"type":"text","fields":{"keyword":{"type":"keyword"}}},"user":{"properties":{"age":{"type":"long"}}}}}}}`: {"title": "text", "title.keyword": "keyword", "user.age": "long"},
+	// mapping with document types before 7.x
+	`{"ark":{"mappings":{"doc":{"properties":{"title":{"type":"string"},"city":{"type":"string","index":"not_analyzed"},"ts":{"type":"date"}}}}}}`: {"title": "string", "city": "keyword", "ts": "date"},
+}
+
+func TestFieldTypesFromMapping(t *testing.T) {
+	for k, v := range mappingCaseMap {
+		fieldTypes, err := FieldTypesFromMapping([]byte(k))
+		if err != nil {
+			t.Error("parse mapping failed", k, err)
+		}
+		if !reflect.DeepEqual(fieldTypes, v) {
+			t.Error("the field types are not equal to expected", k, fieldTypes)
+		}
+	}
+
+	_, err := FieldTypesFromMapping([]byte(`{"ark":{"mappings":{}}}`))
+	if ErrorCodeOf(err) != ErrCodeInvalidValue {
+		t.Error("can not be true, there is no field in the mapping")
+	}
+}
+
+var columnsCaseMap = map[string][]Column{
+	"select name as n, id, ark.*, * from ark": {
+		{Name: "n", Key: "name", Kind: FieldColumn},
+		{Name: "id", Key: "id", Kind: FieldColumn},
+		{Name: "ark.*", Key: "ark.*", Kind: FieldColumn},
+		{Name: "*", Key: "*", Kind: FieldColumn},
+	},
+	"select price * qty as total, id from ark": {
+		{Name: "total", Key: "total", Kind: ScriptColumn},
+		{Name: "id", Key: "id", Kind: FieldColumn},
+	},
+	"select count(*) as c, sum(x) from ark": {
+		{Name: "c", Key: "c", Kind: MetricColumn},
+		{Name: "sum(x)", Key: "SUM(x)", Kind: MetricColumn},
+	},
+	"select region, avg(user.age) from ark group by region": {
+		{Name: "region", Key: "region", Kind: BucketColumn},
+		{Name: "avg(user.age)", Key: "AVG(user_age)", Kind: MetricColumn},
+	},
+	"select region, date_histogram(field='ctime') as day, count(*) from ark group by region, day": {
+		{Name: "region", Key: "region", Kind: BucketColumn},
+		{Name: "day", Key: "day", Kind: BucketColumn},
+		{Name: "count(*)", Key: "COUNT(*)", Kind: MetricColumn},
+	},
+}
+
+func TestConvertStatement(t *testing.T) {
+	for k, v := range columnsCaseMap {
+		stmt, err := ConvertStatement(k, Options{})
+		if err != nil {
+			t.Error("convert statement failed", k, err)
+			continue
+		}
+		if stmt.Table != "ark" {
+			t.Error("the table is not equal to expected", k, stmt.Table)
+		}
+		if !reflect.DeepEqual(stmt.Columns, v) {
+			t.Error("the columns are not equal to expected", k, stmt.Columns)
+		}
+	}
+}
+
+func TestCompositeAggregation(t *testing.T) {
+	var opts = Options{CompositeAggregation: true}
+	for _, sql := range []string{
+		"select count(*) from ark group by range(age, 1, 2)",
+		"select count(*) from ark group by region order by count(*)",
+		"select count(*) from ark group by region limit 1, 2",
+	} {
+		_, _, err := ConvertWithOptions(sql, opts)
+		if err == nil {
+			t.Error("can not be true, composite aggregation does not support", sql)
+		}
+	}
+
+	dsl, _, err := ConvertWithOptions("select count(*) from ark group by region limit 2", opts)
+	if err != nil {
+		t.Fatal("convert failed", err)
+	}
+
+	next, err := NextCompositePage(dsl, map[string]interface{}{"region": "bj"})
+	if err != nil {
+		t.Fatal("next page failed", err)
+	}
+	var nextMap, expectedMap map[string]interface{}
+	json.Unmarshal([]byte(next), &nextMap)
+	json.Unmarshal([]byte(`{"query" : {"bool" : {"must": [{"match_all" : {}}]}},"from" : 0,"size" : 0,"aggregations" : {"composite":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"composite":{"after":{"region":"bj"},"size":2,"sources":[{"region":{"terms":{"field":"region"}}}]}}}}`), &expectedMap)
+	if !reflect.DeepEqual(nextMap, expectedMap) {
+		t.Error("the next page is not equal to expected", next)
+	}
+
+	// the large integers of the query are not rounded
+	dsl, _, _ = ConvertWithOptions("select count(*) from ark where id = 9007199254740993 group by region", opts)
+	next, err = NextCompositePage(dsl, map[string]interface{}{"region": "bj"})
+	if err != nil || !strings.Contains(next, `"query":9007199254740993`) {
+		t.Error("the next page is not equal to expected", next, err)
+	}
+
+	_, err = NextCompositePage(dsl, nil)
+	if ErrorCodeOf(err) != ErrCodeInvalidValue {
+		t.Error("can not be true, there is no after key")
+	}
+
+	dsl, _, _ = Convert("select count(*) from ark group by region")
+	_, err = NextCompositePage(dsl, map[string]interface{}{"region": "bj"})
+	if ErrorCodeOf(err) != ErrCodeInvalidValue {
+		t.Error("can not be true, there is no composite aggregation")
+	}
+}
+
+var errorCodeCaseMap = map[string]ErrorCode{
+	"select * frm a":                                             ErrCodeSyntax,
+	"show tables":                                                ErrCodeUnsupported,
+	"select * from a where a <=> 1":                              ErrCodeUnsupported,
+	"select * from a,b":                                          ErrCodeUnsupported,
+	"select * from a limit 'x'":                                  ErrCodeInvalidValue,
+	"select * from a where a like 1":                             ErrCodeInvalidValue,
+	"select * from a where a = 0x":                               ErrCodeInvalidValue,
+	"select * from a where a = 99e999999":                        ErrCodeInvalidValue,
+	"select * from a where a = ?":                                ErrCodeInvalidValue,
+	"select * from a where a = :name":                            ErrCodeInvalidValue,
+	"select * from a where a = ? and b = :v1":                    ErrCodeInvalidValue,
+	"select * from a order by ?":                                 ErrCodeUnsupported,
+	"select * from a group by ?":                                 ErrCodeUnsupported,
+	"select * from a where id in (select id from b where c = ?)": ErrCodeUnsupported,
+	"select count(*) from a group by b, c limit 3":               ErrCodeUnsupported,
+	"select * from a where a regexp '(^a|b)'":                    ErrCodeUnsupported,
+	"select * from a where a regexp 'x(a$|b)'":                   ErrCodeUnsupported,
+}
+
+func TestErrors(t *testing.T) {
+	for k, v := range errorCodeCaseMap {
+		_, _, err := Convert(k)
+		if code := ErrorCodeOf(err); code != v {
+			t.Error("the error code is not equal to expected", k, code, err)
+		}
+	}
+
+	_, _, err := Convert("select * frm a")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Position != 13 || syntaxErr.Near != "frm" {
+		t.Error("the syntax error is not equal to expected", err)
+	}
+
+	_, _, err = Convert("select * from a where a <=> 1")
+	var unsupportedErr *UnsupportedError
+	if !errors.As(err, &unsupportedErr) || unsupportedErr.Node != "operator" || unsupportedErr.Fragment != "<=>" {
+		t.Error("the unsupported error is not equal to expected", err)
+	}
+
+	_, _, err = ConvertWithOptions("select * from a order by ?", Options{Params: []interface{}{"x"}})
+	if !errors.As(err, &unsupportedErr) || unsupportedErr.Fragment != "? asc" {
+		t.Error("the unsupported error is not equal to expected", err)
+	}
+
+	// the named placeholders never take the params of ?
+	_, _, err = ConvertWithOptions("select * from a where a = :v1", Options{Params: []interface{}{1}})
+	if ErrorCodeOf(err) != ErrCodeInvalidValue {
+		t.Error("can not be true, :v1 has no named param", err)
+	}
+
+	if ErrorCodeOf(errors.New("x")) != ErrCodeUnknown {
+		t.Error("can not be true, the error is not returned by the translation")
+	}
+}
+
+// the dsl of each target version, the selected columns are requested as docvalue_fields and stored_fields too
+var versionCaseMap = map[Version]map[string]string{
+	Elasticsearch2: {
+		"select * from ark where a >= 1 and b between 2 and 3":                                                                   `{"query":{"bool":{"must":[{"range":{"a":{"gte":1}}},{"range":{"b":{"gte":2,"lte":3}}}]}},"from":0,"size":1}`,
+		"select count(*) from ark group by region, city order by city desc":                                                      `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"city","order":[{"_term":"desc"}],"size":0}}},"terms":{"field":"region","size":200}}}}`,
+		"select count(*) from ark group by date_histogram(field='ctime', value='1d'), date_histogram(field='mtime', value='4h')": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"date_histogram(field=ctime,value=1d)":{"aggregations":{"date_histogram(field=mtime,value=4h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"mtime","format":"yyyy-MM-dd HH:mm:ss","interval":"4h"}}},"date_histogram":{"field":"ctime","format":"yyyy-MM-dd HH:mm:ss","interval":"1d"}}}}`,
+		"select count(*) from ark group by region having count(*) > 1":                                                           `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"p0 > 1"}}},"terms":{"field":"region","size":200}}}}`,
+		"select id from ark": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":1,"_source":["id"],"fielddata_fields":["id"],"fields":["id"]}`,
+	},
+	Elasticsearch5: {
+		"select * from ark where a >= 1 and b between 2 and 3":                                                                   `{"query":{"bool":{"must":[{"range":{"a":{"gte":1}}},{"range":{"b":{"gte":2,"lte":3}}}]}},"from":0,"size":1}`,
+		"select count(*) from ark group by region, city order by city desc":                                                      `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"city","order":[{"_term":"desc"}],"size":200}}},"terms":{"field":"region","size":200}}}}`,
+		"select count(*) from ark group by date_histogram(field='ctime', value='1d'), date_histogram(field='mtime', value='4h')": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"date_histogram(field=ctime,value=1d)":{"aggregations":{"date_histogram(field=mtime,value=4h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"mtime","format":"yyyy-MM-dd HH:mm:ss","interval":"4h"}}},"date_histogram":{"field":"ctime","format":"yyyy-MM-dd HH:mm:ss","interval":"1d"}}}}`,
+		"select count(*) from ark group by region having count(*) > 1":                                                           `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 1"}}},"terms":{"field":"region","size":200}}}}`,
+		"select price * qty as total from ark where a > b":                                                                       `{"query":{"bool":{"must":[{"script":{"script":{"inline":"doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value > doc['b'].value","lang":"painless"}}}]}},"from":0,"size":1,"_source":false,"script_fields":{"total":{"script":{"inline":"doc['price'].value * doc['qty'].value","lang":"painless"}}}}`,
+		"select id from ark":                             `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":1,"_source":["id"],"docvalue_fields":["id"],"stored_fields":["id"]}`,
+		"delete from ark where id = 1 limit 10":          `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"size":10}`,
+		"update ark set n = n + 1 where id = 1 limit 10": `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"script":{"inline":"ctx._source['n'] = ctx._source['n'] == null ? null : ctx._source['n'] + params.p0","lang":"painless","params":{"p0":1}},"size":10}`,
+	},
+	Elasticsearch6: {
+		"select * from ark where a >= 1 and b between 2 and 3":                                                                   `{"query":{"bool":{"must":[{"range":{"a":{"gte":1}}},{"range":{"b":{"gte":2,"lte":3}}}]}},"from":0,"size":1}`,
+		"select count(*) from ark group by region, city order by city desc":                                                      `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"city","order":[{"_key":"desc"}],"size":200}}},"terms":{"field":"region","size":200}}}}`,
+		"select count(*) from ark group by date_histogram(field='ctime', value='1d'), date_histogram(field='mtime', value='4h')": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"date_histogram(field=ctime,value=1d)":{"aggregations":{"date_histogram(field=mtime,value=4h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"mtime","format":"yyyy-MM-dd HH:mm:ss","interval":"4h"}}},"date_histogram":{"field":"ctime","format":"yyyy-MM-dd HH:mm:ss","interval":"1d"}}}}`,
+		"select count(*) from ark group by region limit 10, 5":                                                                   `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"limit":{"bucket_sort":{"from":10,"size":5}}},"terms":{"field":"region","size":15}}}}`,
+		"select count(*) from ark group by region having count(*) > 1":                                                           `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 1"}}},"terms":{"field":"region","size":200}}}}`,
+		"select price * qty as total from ark where a > b":                                                                       `{"query":{"bool":{"must":[{"script":{"script":{"lang":"painless","source":"doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value > doc['b'].value"}}}]}},"from":0,"size":1,"_source":false,"script_fields":{"total":{"script":{"lang":"painless","source":"doc['price'].value * doc['qty'].value"}}}}`,
+		"select id from ark":                             `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":1,"_source":["id"],"docvalue_fields":["id"],"stored_fields":["id"]}`,
+		"delete from ark where id = 1 limit 10":          `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"size":10}`,
+		"update ark set n = n + 1 where id = 1 limit 10": `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"script":{"lang":"painless","params":{"p0":1},"source":"ctx._source['n'] = ctx._source['n'] == null ? null : ctx._source['n'] + params.p0"},"size":10}`,
+		"insert into ark (_id, name) values (1, 'x')":    "{\"create\":{\"_id\":\"1\",\"_index\":\"ark\",\"_type\":\"_doc\"}}\n{\"name\":\"x\"}\n",
+	},
+	Elasticsearch7: {
+		"select * from ark where a >= 1 and b between 2 and 3":                                                                   `{"query":{"bool":{"must":[{"range":{"a":{"gte":1}}},{"range":{"b":{"gte":2,"lte":3}}}]}},"from":0,"size":1}`,
+		"select count(*) from ark group by region, city order by city desc":                                                      `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"city","order":[{"_key":"desc"}],"size":200}}},"terms":{"field":"region","size":200}}}}`,
+		"select count(*) from ark group by date_histogram(field='ctime', value='1d'), date_histogram(field='mtime', value='4h')": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"date_histogram(field=ctime,value=1d)":{"aggregations":{"date_histogram(field=mtime,value=4h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"mtime","fixed_interval":"4h","format":"yyyy-MM-dd HH:mm:ss"}}},"date_histogram":{"calendar_interval":"1d","field":"ctime","format":"yyyy-MM-dd HH:mm:ss"}}}}`,
+		"select count(*) from ark group by region limit 10, 5":                                                                   `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"limit":{"bucket_sort":{"from":10,"size":5}}},"terms":{"field":"region","size":15}}}}`,
+		"select count(*) from ark group by region having count(*) > 1":                                                           `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 1"}}},"terms":{"field":"region","size":200}}}}`,
+		"select price * qty as total from ark where a > b":                                                                       `{"query":{"bool":{"must":[{"script":{"script":{"lang":"painless","source":"doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value > doc['b'].value"}}}]}},"from":0,"size":1,"_source":false,"script_fields":{"total":{"script":{"lang":"painless","source":"doc['price'].value * doc['qty'].value"}}}}`,
+		"select id from ark":                             `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":1,"_source":["id"],"docvalue_fields":["id"],"stored_fields":["id"]}`,
+		"delete from ark where id = 1 limit 10":          `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"max_docs":10}`,
+		"update ark set n = n + 1 where id = 1 limit 10": `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"script":{"lang":"painless","params":{"p0":1},"source":"ctx._source['n'] = ctx._source['n'] == null ? null : ctx._source['n'] + params.p0"},"max_docs":10}`,
+		"insert into ark (_id, name) values (1, 'x')":    "{\"create\":{\"_id\":\"1\",\"_index\":\"ark\"}}\n{\"name\":\"x\"}\n",
+	},
+	Elasticsearch8: {
+		"select * from ark where a >= 1 and b between 2 and 3":                                                                   `{"query":{"bool":{"must":[{"range":{"a":{"gte":1}}},{"range":{"b":{"gte":2,"lte":3}}}]}},"from":0,"size":1}`,
+		"select count(*) from ark group by region, city order by city desc":                                                      `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"city","order":[{"_key":"desc"}],"size":200}}},"terms":{"field":"region","size":200}}}}`,
+		"select count(*) from ark group by date_histogram(field='ctime', value='1d'), date_histogram(field='mtime', value='4h')": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"date_histogram(field=ctime,value=1d)":{"aggregations":{"date_histogram(field=mtime,value=4h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"mtime","fixed_interval":"4h","format":"yyyy-MM-dd HH:mm:ss"}}},"date_histogram":{"calendar_interval":"1d","field":"ctime","format":"yyyy-MM-dd HH:mm:ss"}}}}`,
+		"select count(*) from ark group by region limit 10, 5":                                                                   `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"limit":{"bucket_sort":{"from":10,"size":5}}},"terms":{"field":"region","size":15}}}}`,
+		"select count(*) from ark group by region having count(*) > 1":                                                           `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 1"}}},"terms":{"field":"region","size":200}}}}`,
+		"select price * qty as total from ark where a > b":                                                                       `{"query":{"bool":{"must":[{"script":{"script":{"lang":"painless","source":"doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value > doc['b'].value"}}}]}},"from":0,"size":1,"_source":false,"script_fields":{"total":{"script":{"lang":"painless","source":"doc['price'].value * doc['qty'].value"}}}}`,
+		"select id from ark":                             `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":1,"_source":["id"],"docvalue_fields":["id"],"stored_fields":["id"]}`,
+		"delete from ark where id = 1 limit 10":          `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"max_docs":10}`,
+		"update ark set n = n + 1 where id = 1 limit 10": `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"script":{"lang":"painless","params":{"p0":1},"source":"ctx._source['n'] = ctx._source['n'] == null ? null : ctx._source['n'] + params.p0"},"max_docs":10}`,
+		"insert into ark (_id, name) values (1, 'x')":    "{\"create\":{\"_id\":\"1\",\"_index\":\"ark\"}}\n{\"name\":\"x\"}\n",
+	},
+	OpenSearch: {
+		"select * from ark where a >= 1 and b between 2 and 3":                                                                   `{"query":{"bool":{"must":[{"range":{"a":{"gte":1}}},{"range":{"b":{"gte":2,"lte":3}}}]}},"from":0,"size":1}`,
+		"select count(*) from ark group by region, city order by city desc":                                                      `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"city":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"terms":{"field":"city","order":[{"_key":"desc"}],"size":200}}},"terms":{"field":"region","size":200}}}}`,
+		"select count(*) from ark group by date_histogram(field='ctime', value='1d'), date_histogram(field='mtime', value='4h')": `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"date_histogram(field=ctime,value=1d)":{"aggregations":{"date_histogram(field=mtime,value=4h)":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}}},"date_histogram":{"field":"mtime","fixed_interval":"4h","format":"yyyy-MM-dd HH:mm:ss"}}},"date_histogram":{"calendar_interval":"1d","field":"ctime","format":"yyyy-MM-dd HH:mm:ss"}}}}`,
+		"select count(*) from ark group by region limit 10, 5":                                                                   `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"limit":{"bucket_sort":{"from":10,"size":5}}},"terms":{"field":"region","size":15}}}}`,
+		"select count(*) from ark group by region having count(*) > 1":                                                           `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":0,"aggregations":{"region":{"aggregations":{"COUNT(*)":{"value_count":{"field":"_index"}},"having":{"bucket_selector":{"buckets_path":{"p0":"_count"},"script":"params.p0 > 1"}}},"terms":{"field":"region","size":200}}}}`,
+		"select price * qty as total from ark where a > b":                                                                       `{"query":{"bool":{"must":[{"script":{"script":{"lang":"painless","source":"doc['a'].size() != 0 && doc['b'].size() != 0 && doc['a'].value > doc['b'].value"}}}]}},"from":0,"size":1,"_source":false,"script_fields":{"total":{"script":{"lang":"painless","source":"doc['price'].value * doc['qty'].value"}}}}`,
+		"select id from ark":                             `{"query":{"bool":{"must":[{"match_all":{}}]}},"from":0,"size":1,"_source":["id"],"docvalue_fields":["id"],"stored_fields":["id"]}`,
+		"delete from ark where id = 1 limit 10":          `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"max_docs":10}`,
+		"update ark set n = n + 1 where id = 1 limit 10": `{"query":{"bool":{"must":[{"match_phrase":{"id":{"query":1}}}]}},"script":{"lang":"painless","params":{"p0":1},"source":"ctx._source['n'] = ctx._source['n'] == null ? null : ctx._source['n'] + params.p0"},"max_docs":10}`,
+		"insert into ark (_id, name) values (1, 'x')":    "{\"create\":{\"_id\":\"1\",\"_index\":\"ark\"}}\n{\"name\":\"x\"}\n",
+	},
+}
+
+var versionUnsupportedCaseMap = map[Version][]string{
+	Elasticsearch2: {
+		"select count(*) from ark group by region limit 10, 5",
+		"select price * qty as total from ark where a > b",
+		"delete from ark where id = 1 limit 10",
+		"update ark set n = n + 1 where id = 1 limit 10",
+		"insert into ark (_id, name) values (1, 'x')",
+	},
+	Elasticsearch5: {
+		"select count(*) from ark group by region limit 10, 5",
+		"insert into ark (_id, name) values (1, 'x')",
+	},
+}
+
+func TestTargetVersion(t *testing.T) {
+	for version, caseMap := range versionCaseMap {
+		opts := Options{TargetVersion: version, DocValueFields: true, StoredFields: true}
+		for k, v := range caseMap {
+			stmt, err := ConvertStatement(k, opts)
+			if err != nil {
+				t.Error("convert with target version failed", version, k, err)
+				continue
+			}
+
+			// the ndjson of bulk is compared as it is
+			if stmt.Endpoint == BulkEndpoint {
+				if stmt.DSL != v {
+					t.Error("the generated dsl is not equal to expected", version, k, stmt.DSL)
+				}
+				continue
+			}
+
+			var dslMap, dslConvertedMap map[string]interface{}
+			err = json.Unmarshal([]byte(v), &dslMap)
+			if err != nil {
+				t.Error("test case json unmarshal err!", version, k)
+			}
+			err = json.Unmarshal([]byte(stmt.DSL), &dslConvertedMap)
+			if err != nil {
+				t.Error("the generated dsl json unmarshal error!", version, k)
+			}
+			if !reflect.DeepEqual(dslMap, dslConvertedMap) {
+				t.Error("the generated dsl is not equal to expected", version, k, stmt.DSL)
+			}
+		}
+	}
+
+	for version, sqls := range versionUnsupportedCaseMap {
+		for _, v := range sqls {
+			_, err := ConvertStatement(v, Options{TargetVersion: version})
+			if ErrorCodeOf(err) != ErrCodeUnsupported {
+				t.Error("can not be true, these cases are not supported by the version!", version, v, err)
+			}
+		}
+	}
+
+	_, err := ConvertStatement("select count(*) from ark group by region", Options{TargetVersion: Elasticsearch5, CompositeAggregation: true})
+	if ErrorCodeOf(err) != ErrCodeUnsupported {
+		t.Error("can not be true, composite aggregation is not supported by elasticsearch 5.x", err)
+	}
+
+	for _, name := range []string{"default", "es2", "es5", "es6", "es7", "es8", "opensearch"} {
+		version, err := ParseVersion(name)
+		if err != nil || version.String() != name {
+			t.Error("the parsed version is not equal to expected", name, version, err)
+		}
+	}
+	if _, err := ParseVersion("es9"); ErrorCodeOf(err) != ErrCodeInvalidValue {
+		t.Error("can not be true, es9 is not a version", err)
+	}
+}
+
+var splitCaseMap = map[string][]string{
+	"select * from a":                                              {"select * from a"},
+	"select * from a; select ';' from b;":                          {"select * from a", "select ';' from b"},
+	" ; ;select * from a where b = 1 -- x;\n":                      {"select * from a where b = 1 -- x;"},
+	"delete from a where id = 1;\n\ninsert into a (id) values (1)": {"delete from a where id = 1", "insert into a (id) values (1)"},
+}
+
+func TestSplitStatements(t *testing.T) {
+	for k, v := range splitCaseMap {
+		statements, err := SplitStatements(k)
+		if err != nil || !reflect.DeepEqual(statements, v) {
+			t.Error("the statements are not equal to expected", k, statements, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package elasticsql
+
+// Statement is the translation of a sql statement
+type Statement struct {
+	// DSL is the body of the request, the ndjson of the actions for _bulk
+	DSL string
+	// Table is the index or type of the statement
+	Table string
+	// Endpoint is the api the DSL is sent to, eg. _search, _delete_by_query, _update_by_query, _bulk
+	Endpoint string
+	// Columns are the columns of select in order, they map the response back to the sql columns
+	Columns []Column
+	// Buckets are the names of the nested bucket aggregations of group by, from the outermost to the innermost,
+	// it is the composite aggregation only when Options.CompositeAggregation is set
+	Buckets []string
+	// Ignore is set for insert ignore, the documents which already exist are not errors
+	// and are left as they are, the version conflicts of their create actions can be skipped
+	Ignore bool
+}
+
+// the apis of the requests
+const (
+	// SearchEndpoint is the endpoint of select
+	SearchEndpoint = "_search"
+	// DeleteByQueryEndpoint is the endpoint of delete
+	DeleteByQueryEndpoint = "_delete_by_query"
+	// UpdateByQueryEndpoint is the endpoint of update
+	UpdateByQueryEndpoint = "_update_by_query"
+	// BulkEndpoint is the endpoint of insert and replace, the DSL is ndjson
+	BulkEndpoint = "_bulk"
+)
+
+// ColumnKind tells where the value of a column is in the response
+type ColumnKind int
+
+const (
+	// FieldColumn is a field of the hits, Key is the path of the field, or * and x.* for all the fields
+	FieldColumn ColumnKind = iota
+	// BucketColumn is the key of the buckets of the aggregation named Key,
+	// or the source named Key of the composite aggregation
+	BucketColumn
+	// MetricColumn is the value of the metric aggregation named Key in the innermost buckets,
+	// count(*) is also the doc_count of the innermost buckets
+	MetricColumn
+	// ScriptColumn is the script field of the hits named Key, computed from the select expression
+	ScriptColumn
+)
+
+// Column is a column of select
+type Column struct {
+	// Name is the alias of the column, or the column itself as written in sql
+	Name string
+	// Key is the name of the field or the aggregation in the response
+	Key  string
+	Kind ColumnKind
+}
@@ -0,0 +1,120 @@
+package elasticsql
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// columnPath splits the column into the fields from the outermost object,
+// a.b is the field b of the object a while `a.b` is the field named a.b
+func columnPath(col *sqlparser.ColName) []string {
+	var path []string
+	for _, name := range []string{col.Qualifier.Qualifier.String(), col.Qualifier.Name.String(), col.Name.String()} {
+		if name != "" {
+			path = append(path, name)
+		}
+	}
+	return path
+}
+
+// sourceField reads the field of the document being updated,
+// it is null rather than throwing when the field or one of its objects is missing
+func sourceField(col *sqlparser.ColName) string {
+	path := columnPath(col)
+	field := "ctx._source[" + painlessString(path[0]) + "]"
+	for _, name := range path[1:] {
+		field += "?.get(" + painlessString(name) + ")"
+	}
+	return field
+}
+
+// assignField assigns value to the field of the document being updated,
+// the missing objects on the way to the field are created
+func assignField(col *sqlparser.ColName, value string) string {
+	path := columnPath(col)
+	var statements []string
+	field := "ctx._source"
+	for _, name := range path[:len(path)-1] {
+		field += "[" + painlessString(name) + "]"
+		statements = append(statements, "if ("+field+" == null) { "+field+" = new HashMap(); }")
+	}
+	field += "[" + painlessString(path[len(path)-1]) + "]"
+	return strings.Join(append(statements, field+" = "+value), " ")
+}
+
+// handleUpdate translates update to an _update_by_query request
+// the set clause becomes a painless script assigning ctx._source, the values are passed as params
+// the where is translated the same as select, limit is max_docs
+func handleUpdate(upd *sqlparser.Update, opts *Options) (*Statement, error) {
+	if len(upd.TableExprs) != 1 {
+		return nil, unsupportedError("table", sqlparser.String(upd.TableExprs), "update of multiple tables is not supported")
+	}
+	esType := strings.Replace(sqlparser.String(upd.TableExprs), "`", "", -1)
+
+	if len(upd.OrderBy) > 0 {
+		return nil, unsupportedError("clause", sqlparser.String(upd.OrderBy), "order by in update is not supported")
+	}
+
+	var req = updateByQueryRequest{}
+	if upd.Where != nil {
+		var err error
+		req.Query, err = handleSelectWhere(&upd.Where.Expr, true, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		req.Query = &boolQuery{must: []query{&matchAllQuery{}}}
+	}
+
+	// the values may refer to the fields of the document, eg. retries = retries + 1
+	if err := opts.checkPainless(sqlparser.String(upd.Exprs)); err != nil {
+		return nil, err
+	}
+	b := newScriptBuilder(opts)
+	b.field = sourceField
+	var assignments []string
+	for _, updateExpr := range upd.Exprs {
+		b.columns = nil
+		value, err := b.build(updateExpr.Expr)
+		if err != nil {
+			return nil, err
+		}
+		// like sql the value is null when a field it reads is null, instead of a null pointer exception
+		var nulls []string
+		for _, col := range b.columns {
+			nulls = append(nulls, sourceField(col)+" == null")
+		}
+		if len(nulls) > 0 {
+			value = strings.Join(nulls, " || ") + " ? null : " + value
+		}
+		assignments = append(assignments, assignField(updateExpr.Name, value))
+	}
+	req.Script = b.script(strings.Join(assignments, "; "))
+
+	if upd.Limit != nil {
+		var err error
+		var maxDocs int
+		maxDocs, err = buildMaxDocs(upd.Limit)
+		if err != nil {
+			return nil, err
+		}
+		if opts.maxDocsKey() == "size" {
+			req.Size = maxDocs
+		} else {
+			req.MaxDocs = maxDocs
+		}
+	}
+
+	dslBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Statement{
+		DSL:      string(dslBytes),
+		Table:    esType,
+		Endpoint: UpdateByQueryEndpoint,
+	}, nil
+}
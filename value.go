@@ -0,0 +1,104 @@
+package elasticsql
+
+import (
+	"encoding/hex"
+	"strconv"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// buildValue converts a sql literal to the go value with the same json type
+// numbers become int64/uint64/float64, strings stay strings,
+// true/false become bool and null becomes nil
+func buildValue(expr sqlparser.Expr) (interface{}, error) {
+	switch e := expr.(type) {
+	case *sqlparser.SQLVal:
+		return buildSQLVal(e)
+	case sqlparser.BoolVal:
+		return bool(e), nil
+	case *sqlparser.NullVal:
+		return nil, nil
+	case *sqlparser.UnaryExpr:
+		// -1 is folded into the literal by the parser, but -1.5 is not
+		val, ok := e.Expr.(*sqlparser.SQLVal)
+		if !ok || (e.Operator != sqlparser.UMinusStr && e.Operator != sqlparser.UPlusStr) {
+			break
+		}
+		if val.Type != sqlparser.IntVal && val.Type != sqlparser.FloatVal {
+			break
+		}
+		num, err := buildSQLVal(val)
+		if err != nil || e.Operator == sqlparser.UPlusStr {
+			return num, err
+		}
+		switch n := num.(type) {
+		case int64:
+			return -n, nil
+		case float64:
+			return -n, nil
+		}
+	}
+
+	return nil, unsupportedError("value", sqlparser.String(expr), "unsupported value")
+}
+
+func buildSQLVal(val *sqlparser.SQLVal) (interface{}, error) {
+	var (
+		str = string(val.Val)
+		num interface{}
+		err error
+	)
+
+	switch val.Type {
+	case sqlparser.StrVal:
+		return str, nil
+	case sqlparser.HexVal:
+		// x'4D7953514C' is a string written in hex
+		decoded, err := hex.DecodeString(str)
+		if err != nil {
+			return nil, valueError("hex", sqlparser.String(val), "invalid hex value")
+		}
+		return string(decoded), nil
+	case sqlparser.IntVal:
+		if num, err = strconv.ParseInt(str, 10, 64); err != nil {
+			if num, err = strconv.ParseUint(str, 10, 64); err != nil {
+				// too large for the integer types, keep as much as we can
+				num, err = strconv.ParseFloat(str, 64)
+			}
+		}
+	case sqlparser.FloatVal:
+		num, err = strconv.ParseFloat(str, 64)
+	case sqlparser.HexNum:
+		// 0x1F
+		num, err = strconv.ParseUint(str[2:], 16, 64)
+	case sqlparser.BitVal:
+		// b'0101'
+		num, err = strconv.ParseUint(str, 2, 64)
+	default:
+		return nil, unsupportedError("value", sqlparser.String(val), "unsupported value")
+	}
+
+	if err != nil {
+		return nil, valueError("number", sqlparser.String(val), "invalid number")
+	}
+	return num, nil
+}
+
+// buildValueStr is for the places where only a string makes sense, eg. like patterns
+func buildValueStr(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package elasticsql
+
+import (
+	"regexp"
+)
+
+// Version is the version of elasticsearch or opensearch the dsl is built for
+type Version int
+
+const (
+	// DefaultVersion keeps the dsl of the previous releases, range uses from/to
+	// and date_histogram uses interval, which are deprecated by the newer versions
+	DefaultVersion Version = iota
+	// Elasticsearch2 is elasticsearch 2.x, painless scripts are not available
+	Elasticsearch2
+	// Elasticsearch5 is elasticsearch 5.x
+	Elasticsearch5
+	// Elasticsearch6 is elasticsearch 6.x, bucket_sort needs 6.4 or later
+	Elasticsearch6
+	// Elasticsearch7 is elasticsearch 7.x, calendar_interval and fixed_interval need 7.2 or later
+	Elasticsearch7
+	// Elasticsearch8 is elasticsearch 8.x
+	Elasticsearch8
+	// OpenSearch is opensearch 1.x and 2.x, which is forked from elasticsearch 7.10
+	OpenSearch
+)
+
+var versionNames = map[Version]string{
+	DefaultVersion: "default",
+	Elasticsearch2: "es2",
+	Elasticsearch5: "es5",
+	Elasticsearch6: "es6",
+	Elasticsearch7: "es7",
+	Elasticsearch8: "es8",
+	OpenSearch:     "opensearch",
+}
+
+func (v Version) String() string {
+	if name, ok := versionNames[v]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseVersion returns the version of the name, eg. es7, opensearch
+func ParseVersion(name string) (Version, error) {
+	for v, versionName := range versionNames {
+		if versionName == name {
+			return v, nil
+		}
+	}
+	return DefaultVersion, valueError("version", name, "unknown version, must be one of default, es2, es5, es6, es7, es8, opensearch")
+}
+
+// the newer versions are the ones whose features are used by the default version
+func (opts *Options) isBefore(v Version) bool {
+	return opts.TargetVersion != DefaultVersion && opts.TargetVersion < v
+}
+
+// rangeBound returns the key of the bound of the range query for the operator
+func (opts *Options) rangeBound(operator string) string {
+	switch operator {
+	case ">=":
+		if opts.TargetVersion == DefaultVersion {
+			return "from"
+		}
+		return "gte"
+	case "<=":
+		if opts.TargetVersion == DefaultVersion {
+			return "to"
+		}
+		return "lte"
+	case ">":
+		return "gt"
+	}
+	return "lt"
+}
+
+// innerTermsSize is the size of the terms aggregations nested in another bucket,
+// size 0 means all the terms before 5.x, and is rejected after that
+func (opts *Options) innerTermsSize() int {
+	if opts.TargetVersion == DefaultVersion || opts.TargetVersion == Elasticsearch2 {
+		return 0
+	}
+	return opts.TermsSize
+}
+
+// bucketKeyOrder is the key used to sort the buckets by their keys,
+// the terms aggregation uses _term before 6.x
+func (opts *Options) bucketKeyOrder(kind string) string {
+	if kind == "terms" && opts.isBefore(Elasticsearch6) {
+		return "_term"
+	}
+	return "_key"
+}
+
+// calendarIntervalPattern matches the intervals of a single calendar unit, eg. 1d, month
+var calendarIntervalPattern = regexp.MustCompile(`^(1[mhdwMqy]|minute|hour|day|week|month|quarter|year)$`)
+
+// dateHistogramIntervalKey returns the key of the interval of date_histogram,
+// interval is split to calendar_interval and fixed_interval since 7.2, and removed in 8.x
+func (opts *Options) dateHistogramIntervalKey(interval string) string {
+	switch opts.TargetVersion {
+	case Elasticsearch7, Elasticsearch8, OpenSearch:
+		if calendarIntervalPattern.MatchString(interval) {
+			return "calendar_interval"
+		}
+		return "fixed_interval"
+	}
+	return "interval"
+}
+
+// scriptSourceKey is the key of the source of a script, which is inline before 6.x
+func (opts *Options) scriptSourceKey() string {
+	if opts.isBefore(Elasticsearch6) {
+		return "inline"
+	}
+	return "source"
+}
+
+// checkPainless returns an error for the versions without painless
+func (opts *Options) checkPainless(fragment string) error {
+	if opts.TargetVersion == Elasticsearch2 {
+		return unsupportedError("expression", fragment, "painless script is not supported by elasticsearch 2.x")
+	}
+	return nil
+}
+
+// checkBucketSort returns an error for the versions without bucket_sort and composite aggregation
+func (opts *Options) checkBucketSort(fragment, feature string) error {
+	if opts.isBefore(Elasticsearch6) {
+		return unsupportedError("clause", fragment, feature+" is not supported before elasticsearch 6.x")
+	}
+	return nil
+}
+
+// bucketPathPrefix is the prefix of the buckets_path variables in the bucket_selector script,
+// the variables of the groovy scripts of 2.x have no prefix
+func (opts *Options) bucketPathPrefix() string {
+	if opts.TargetVersion == Elasticsearch2 {
+		return ""
+	}
+	return "params."
+}
+
+// maxDocsKey is the key of the limit of the by query requests, which is size before 7.3
+func (opts *Options) maxDocsKey() string {
+	if opts.isBefore(Elasticsearch7) {
+		return "size"
+	}
+	return "max_docs"
+}